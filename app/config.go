@@ -0,0 +1,137 @@
+package app
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"accounts/app/memory"
+	"accounts/util"
+)
+
+// envPrefix prefixes the environment variables of the flags, e.g. ACCOUNTS_POOL_MAX_CONNS of -pool-max-conns.
+const envPrefix = "ACCOUNTS_"
+
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// Config is the configuration of the server read from the flags or, if a flag isn't set,
+// from the environment variable of the flag.
+type Config struct {
+	Addr    string
+	Storage string
+	ConnStr string
+	Migrate bool
+
+	PoolMinConns int
+	PoolMaxConns int
+	MemoryMaxID  int
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	OptionsPath    string
+	SnapshotPath   string
+	ConflictStatus int
+	ErrorBody      bool
+	LogLevel       string
+	SQLLogSample   int
+}
+
+// ReadConfig parses the arguments, the flags not set fall back to the environment and then to the defaults.
+func ReadConfig(args []string) (*Config, error) {
+	c := &Config{}
+	fs := flag.NewFlagSet("accounts", flag.ContinueOnError)
+	fs.StringVar(&c.Addr, "addr", "0.0.0.0:8888", "address the server listens on")
+	fs.StringVar(&c.Storage, "storage", storagePostgres, "storage of the accounts: postgres or memory")
+	fs.StringVar(&c.ConnStr, "conn", "", "connection string of the postgres storage")
+	fs.BoolVar(&c.Migrate, "migrate", true, "apply the pending migrations of the postgres storage on startup")
+	fs.IntVar(&c.PoolMinConns, "pool-min-conns", 0, "minimum number of the postgres connections, the pgx default if 0")
+	fs.IntVar(&c.PoolMaxConns, "pool-max-conns", 0, "maximum number of the postgres connections, the pgx default if 0")
+	fs.IntVar(&c.MemoryMaxID, "memory-max-id", memory.DefaultMaxID, "greatest account id of the memory storage, the greater ones are rejected")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", 5*time.Second, "timeout of reading a request")
+	fs.DurationVar(&c.WriteTimeout, "write-timeout", 10*time.Second, "timeout of writing a response")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", time.Minute, "timeout of an idle keep-alive connection")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "time the requests in flight are drained for on shutdown")
+	fs.StringVar(&c.OptionsPath, "options", "/tmp/data/options.txt", "path to options.txt with the current timestamp and the run mode")
+	fs.StringVar(&c.SnapshotPath, "snapshot", "", "path to data.zip the storage is loaded from on startup, e.g. /tmp/data/data.zip, postgres requires -migrate on an empty database, a storage already having accounts is not loaded again")
+	fs.IntVar(&c.ConflictStatus, "conflict-status", http.StatusBadRequest, "status of duplicate email or phone responses")
+	fs.BoolVar(&c.ErrorBody, "error-body", false, "write errors as {\"error\": \"...\"} instead of the empty body")
+	fs.StringVar(&c.LogLevel, "log-level", "info", "log level: "+strings.Join(logLevels, ", "))
+	fs.IntVar(&c.SQLLogSample, "sql-log-sample", 100, "log one of every n SQL statements at the debug level, 1 logs all of them")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := setFromEnv(fs); err != nil {
+		return nil, err
+	}
+
+	return c, c.validate()
+}
+
+// setFromEnv sets the flags not set by the arguments from the environment.
+func setFromEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %w", name, setErr)
+			}
+		}
+	})
+
+	return err
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func (c *Config) validate() error {
+	switch c.Storage {
+	case storagePostgres:
+		if c.ConnStr == "" {
+			return fmt.Errorf("connection string is empty")
+		}
+	case storageMemory:
+	default:
+		return fmt.Errorf("unknown storage %q", c.Storage)
+	}
+
+	if c.PoolMinConns < 0 || c.PoolMaxConns < 0 || (c.PoolMaxConns > 0 && c.PoolMinConns > c.PoolMaxConns) {
+		return fmt.Errorf("invalid pool size: min %d, max %d", c.PoolMinConns, c.PoolMaxConns)
+	}
+
+	if c.MemoryMaxID <= 0 || c.MemoryMaxID > math.MaxInt32 {
+		return fmt.Errorf("invalid memory max id %d", c.MemoryMaxID)
+	}
+
+	if c.SQLLogSample <= 0 {
+		return fmt.Errorf("invalid SQL log sample %d", c.SQLLogSample)
+	}
+
+	_, err := util.ParseLevel(c.LogLevel)
+	return err
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setEnv(t *testing.T, name, value string) {
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() { os.Unsetenv(name) })
+}
+
+func Test_ReadConfig(t *testing.T) {
+	setEnv(t, "ACCOUNTS_POOL_MAX_CONNS", "16")
+	setEnv(t, "ACCOUNTS_ADDR", "127.0.0.1:9000")
+	setEnv(t, "ACCOUNTS_READ_TIMEOUT", "3s")
+
+	config, err := ReadConfig([]string{"-storage", "memory", "-addr", ":8080", "-log-level", "debug"})
+	require.NoError(t, err)
+
+	// the flags take precedence over the environment
+	assert.Equal(t, ":8080", config.Addr)
+	assert.Equal(t, 16, config.PoolMaxConns)
+	assert.Equal(t, 3*time.Second, config.ReadTimeout)
+	assert.Equal(t, 10*time.Second, config.WriteTimeout)
+	assert.Equal(t, storageMemory, config.Storage)
+	assert.Equal(t, "debug", config.LogLevel)
+}
+
+func Test_ReadConfig_Invalid(t *testing.T) {
+	testcases := [][]string{
+		{"-storage", "postgres"},
+		{"-storage", "files"},
+		{"-storage", "memory", "-log-level", "trace"},
+		{"-storage", "memory", "-sql-log-sample", "0"},
+		{"-storage", "memory", "-memory-max-id", "0"},
+		{"-storage", "memory", "-memory-max-id", "4294967296"},
+		{"-storage", "memory", "-pool-min-conns", "8", "-pool-max-conns", "4"},
+	}
+
+	for _, args := range testcases {
+		_, err := ReadConfig(args)
+		assert.Error(t, err, args)
+	}
+
+	setEnv(t, "ACCOUNTS_IDLE_TIMEOUT", "forever")
+	_, err := ReadConfig([]string{"-storage", "memory"})
+	assert.Error(t, err)
+}
+
+func Test_Run_GracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	config, err := ReadConfig([]string{"-storage", "memory", "-addr", addr, "-options", ""})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, config)
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/accounts/filter/?limit=1&query_id=1")
+		if err != nil {
+			return false
+		}
+
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case err = <-done:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("server hasn't shut down")
+	}
+}
@@ -1,14 +1,17 @@
 package controller
 
 import (
-	"log"
 	"net/http"
 
+	"accounts/domain"
 	"accounts/util"
 )
 
 type Controller struct {
-	service accountService
+	service        accountService
+	logger         *util.Logger
+	errorBody      bool
+	conflictStatus int
 }
 
 func New(service accountService) *Controller {
@@ -17,40 +20,84 @@ func New(service accountService) *Controller {
 	}
 }
 
+// WithErrorBody makes error responses carry {"error": "..."} instead of the empty body the contest expects.
+func (c *Controller) WithErrorBody() *Controller {
+	c.errorBody = true
+	return c
+}
+
+// WithLogger sets the logger of the errors used if the request doesn't carry one.
+func (c *Controller) WithLogger(l *util.Logger) *Controller {
+	c.logger = l
+	return c
+}
+
+// WithConflictStatus overrides 409 returned for duplicates, the contest expects 400.
+func (c *Controller) WithConflictStatus(status int) *Controller {
+	c.conflictStatus = status
+	return c
+}
+
 func (c *Controller) FilterAccounts(w http.ResponseWriter, r *http.Request) {
 	body, err := c.service.FilterAccounts(r.Context(), r.URL.Query())
 	if err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, err)
 		return
 	}
 
 	util.WriteSuccessResponse(w, body, http.StatusOK)
 }
 
+// ExplainFilter writes how the filter of the same query would be evaluated, for debugging.
+func (c *Controller) ExplainFilter(w http.ResponseWriter, r *http.Request) {
+	body, err := c.service.ExplainFilter(r.Context(), r.URL.Query())
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	util.WriteTextResponse(w, body, http.StatusOK)
+}
+
 func (c *Controller) GroupAccounts(w http.ResponseWriter, r *http.Request) {
-	log.Println("group accounts")
-	w.WriteHeader(http.StatusNotImplemented)
+	body, err := c.service.GroupAccounts(r.Context(), r.URL.Query())
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	util.WriteSuccessResponse(w, body, http.StatusOK)
 }
 
 func (c *Controller) GetRecommends(w http.ResponseWriter, r *http.Request) {
-	log.Println("get recommends")
-	w.WriteHeader(http.StatusNotImplemented)
+	body, err := c.service.RecommendAccounts(r.Context(), util.ReadURLParam(r, "id"), r.URL.Query())
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	util.WriteSuccessResponse(w, body, http.StatusOK)
 }
 
 func (c *Controller) GetSuggestions(w http.ResponseWriter, r *http.Request) {
-	log.Println("get suggestions")
-	w.WriteHeader(http.StatusNotImplemented)
+	body, err := c.service.SuggestAccounts(r.Context(), util.ReadURLParam(r, "id"), r.URL.Query())
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	util.WriteSuccessResponse(w, body, http.StatusOK)
 }
 
 func (c *Controller) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	body, err := util.ReadRequestBody(r)
 	if err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, domain.NewValidationError(err))
 		return
 	}
 
 	if err = c.service.AddAccount(r.Context(), body); err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, err)
 		return
 	}
 
@@ -60,17 +107,18 @@ func (c *Controller) CreateAccount(w http.ResponseWriter, r *http.Request) {
 func (c *Controller) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 	id := util.ReadURLParam(r, "id")
 	if id == "" {
-		util.WriteErrorResponse(w, nil, http.StatusBadRequest)
+		c.writeError(w, r, domain.NewValidationError(errEmptyID))
+		return
 	}
 
 	body, err := util.ReadRequestBody(r)
 	if err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, domain.NewValidationError(err))
 		return
 	}
 
-	if err = c.service.UpdateAccount(r.Context(), body); err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+	if err = c.service.UpdateAccount(r.Context(), id, body); err != nil {
+		c.writeError(w, r, err)
 		return
 	}
 
@@ -80,12 +128,12 @@ func (c *Controller) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 func (c *Controller) AddLikes(w http.ResponseWriter, r *http.Request) {
 	body, err := util.ReadRequestBody(r)
 	if err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, domain.NewValidationError(err))
 		return
 	}
 
 	if err = c.service.AddLikes(r.Context(), body); err != nil {
-		util.WriteErrorResponse(w, err, http.StatusBadRequest)
+		c.writeError(w, r, err)
 		return
 	}
 
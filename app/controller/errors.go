@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"accounts/domain"
+	"accounts/util"
+)
+
+var (
+	errEmptyID = errors.New("empty id")
+)
+
+var errorStatuses = map[domain.ErrorKind]int{
+	domain.ErrorValidation: http.StatusBadRequest,
+	domain.ErrorNotFound:   http.StatusNotFound,
+	domain.ErrorConflict:   http.StatusConflict,
+	domain.ErrorInternal:   http.StatusInternalServerError,
+}
+
+type errorOut struct {
+	Error string `json:"error"`
+}
+
+// writeError writes the status of the error kind, the internal errors are logged as errors,
+// the errors of the client only at the debug level.
+func (c *Controller) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	kind := domain.KindOf(err)
+	status := errorStatuses[kind]
+	if kind == domain.ErrorConflict && c.conflictStatus != 0 {
+		status = c.conflictStatus
+	}
+
+	logger := util.LoggerFrom(r.Context(), c.logger)
+	if kind == domain.ErrorInternal {
+		logger.Error("request failed", "status", status, "error", err)
+	} else {
+		logger.Debug("request rejected", "status", status, "error", err)
+	}
+
+	if !c.errorBody {
+		util.WriteErrorResponse(w, status)
+		return
+	}
+
+	body, _ := jsoniter.Marshal(errorOut{Error: err.Error()})
+	util.WriteErrorResponseWithBody(w, status, body)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"accounts/domain"
+	"accounts/util"
+)
+
+func Test_writeError(t *testing.T) {
+	raw := errors.New("raw")
+	testcases := map[int]error{
+		http.StatusBadRequest:          domain.NewValidationError(raw),
+		http.StatusNotFound:            domain.NewNotFoundError(raw),
+		http.StatusConflict:            domain.NewConflictError(raw),
+		http.StatusInternalServerError: raw,
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/accounts/filter/", nil)
+	for status, err := range testcases {
+		w := httptest.NewRecorder()
+		New(nil).writeError(w, r, err)
+		assert.Equal(t, status, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		w = httptest.NewRecorder()
+		New(nil).WithErrorBody().writeError(w, r, err)
+		assert.Equal(t, status, w.Code)
+		assert.JSONEq(t, `{"error":"raw"}`, w.Body.String())
+	}
+}
+
+func Test_writeError_ConflictStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/accounts/new/", nil)
+	New(nil).WithConflictStatus(http.StatusBadRequest).writeError(w, r, domain.NewConflictError(errors.New("duplicate")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func Test_writeError_Logged(t *testing.T) {
+	var out bytes.Buffer
+	logger := util.NewLogger(&out, util.LevelInfo)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/accounts/filter/", nil)
+	r = r.WithContext(util.WithLogger(r.Context(), logger.With("request_id", "req-1")))
+	New(nil).writeError(w, r, errors.New("connection refused"))
+	assert.Contains(t, out.String(), `level=error msg="request failed" request_id=req-1 status=500 error="connection refused"`)
+
+	// the errors of the client are logged only at the debug level
+	out.Reset()
+	New(nil).WithLogger(logger).writeError(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil),
+		domain.NewValidationError(errors.New("raw")))
+	assert.Empty(t, out.String())
+}
@@ -7,7 +7,11 @@ import (
 
 type accountService interface {
 	FilterAccounts(ctx context.Context, params url.Values) ([]byte, error)
+	ExplainFilter(ctx context.Context, params url.Values) ([]byte, error)
+	GroupAccounts(ctx context.Context, params url.Values) ([]byte, error)
+	RecommendAccounts(ctx context.Context, id string, params url.Values) ([]byte, error)
+	SuggestAccounts(ctx context.Context, id string, params url.Values) ([]byte, error)
 	AddAccount(ctx context.Context, body []byte) error
-	UpdateAccount(ctx context.Context, body []byte) error
+	UpdateAccount(ctx context.Context, id string, body []byte) error
 	AddLikes(ctx context.Context, body []byte) error
 }
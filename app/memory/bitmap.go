@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	containerBits = 1 << 16
+	bitsetWords   = containerBits / 64
+	// maxArrayLen is the cardinality up to which an array container takes less memory than a bitset one
+	maxArrayLen = 4096
+)
+
+// bitmap is a set of account ids in the roaring style: the ids are split by their high 16 bits
+// into containers keeping the low bits, a sorted array while the container is sparse and a bitset
+// when it's dense. So a rare value (e.g. a city) takes memory proportional to its accounts
+// and a frequent one (e.g. a sex) a bit per id. A nil bitmap is empty.
+type bitmap struct {
+	containers []*container
+}
+
+func newBitmap() *bitmap {
+	return &bitmap{}
+}
+
+func bitmapOf(ids ...int32) *bitmap {
+	b := newBitmap()
+	for _, id := range ids {
+		b.Add(id)
+	}
+
+	return b
+}
+
+func (b *bitmap) Add(id int32) {
+	high, low := split(id)
+	for len(b.containers) <= high {
+		b.containers = append(b.containers, nil)
+	}
+
+	if b.containers[high] == nil {
+		b.containers[high] = &container{}
+	}
+
+	b.containers[high].add(low)
+}
+
+func (b *bitmap) Remove(id int32) {
+	high, low := split(id)
+	if c := b.container(high); c != nil {
+		c.remove(low)
+	}
+}
+
+func (b *bitmap) Contains(id int32) bool {
+	high, low := split(id)
+	c := b.container(high)
+	return c != nil && c.contains(low)
+}
+
+func (b *bitmap) Count() int {
+	if b == nil {
+		return 0
+	}
+
+	count := 0
+	for _, c := range b.containers {
+		if c != nil {
+			count += c.n
+		}
+	}
+
+	return count
+}
+
+// And returns the intersection of the bitmaps.
+func (b *bitmap) And(other *bitmap) *bitmap {
+	return b.combine(other, and)
+}
+
+// AndNot returns the ids of b missing in other.
+func (b *bitmap) AndNot(other *bitmap) *bitmap {
+	return b.combine(other, andNot)
+}
+
+// Or returns the union of the bitmaps.
+func (b *bitmap) Or(other *bitmap) *bitmap {
+	return b.combine(other, or)
+}
+
+func (b *bitmap) combine(other *bitmap, op func(x, y *container) *container) *bitmap {
+	n := max(b.len(), other.len())
+	result := &bitmap{containers: make([]*container, n)}
+	for i := 0; i < n; i++ {
+		result.containers[i] = op(b.container(i), other.container(i))
+	}
+
+	return result
+}
+
+// Desc calls fn for the ids in descending order until it returns false.
+func (b *bitmap) Desc(fn func(id int32) bool) {
+	for high := b.len() - 1; high >= 0; high-- {
+		c := b.containers[high]
+		if c == nil {
+			continue
+		}
+
+		base := int32(high) << 16
+		if !c.desc(func(low uint16) bool { return fn(base | int32(low)) }) {
+			return
+		}
+	}
+}
+
+func (b *bitmap) len() int {
+	if b == nil {
+		return 0
+	}
+
+	return len(b.containers)
+}
+
+func (b *bitmap) container(high int) *container {
+	if high >= b.len() {
+		return nil
+	}
+
+	return b.containers[high]
+}
+
+func split(id int32) (high int, low uint16) {
+	return int(id) >> 16, uint16(id)
+}
+
+// container keeps the low bits of the ids: array is sorted and used while bitset is nil.
+type container struct {
+	array  []uint16
+	bitset *[bitsetWords]uint64
+	n      int
+}
+
+func (c *container) add(v uint16) {
+	if c.bitset != nil {
+		if c.bitset[v/64]&(1<<(v%64)) == 0 {
+			c.bitset[v/64] |= 1 << (v % 64)
+			c.n++
+		}
+
+		return
+	}
+
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= v })
+	if i < len(c.array) && c.array[i] == v {
+		return
+	}
+
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = v
+	c.n++
+
+	if c.n > maxArrayLen {
+		c.toBitset()
+	}
+}
+
+func (c *container) remove(v uint16) {
+	if c.bitset != nil {
+		if c.bitset[v/64]&(1<<(v%64)) != 0 {
+			c.bitset[v/64] &^= 1 << (v % 64)
+			c.n--
+		}
+
+		return
+	}
+
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= v })
+	if i < len(c.array) && c.array[i] == v {
+		c.array = append(c.array[:i], c.array[i+1:]...)
+		c.n--
+	}
+}
+
+func (c *container) contains(v uint16) bool {
+	if c.bitset != nil {
+		return c.bitset[v/64]&(1<<(v%64)) != 0
+	}
+
+	i := sort.Search(len(c.array), func(i int) bool { return c.array[i] >= v })
+	return i < len(c.array) && c.array[i] == v
+}
+
+func (c *container) desc(fn func(v uint16) bool) bool {
+	if c.bitset == nil {
+		for i := len(c.array) - 1; i >= 0; i-- {
+			if !fn(c.array[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for w := bitsetWords - 1; w >= 0; w-- {
+		word := c.bitset[w]
+		for word != 0 {
+			bit := 63 - bits.LeadingZeros64(word)
+			word &^= 1 << uint(bit)
+			if !fn(uint16(w*64 + bit)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (c *container) toBitset() {
+	c.bitset = new([bitsetWords]uint64)
+	for _, v := range c.array {
+		c.bitset[v/64] |= 1 << (v % 64)
+	}
+
+	c.array = nil
+}
+
+// normalize picks the representation by the cardinality, an empty container is nil.
+func (c *container) normalize() *container {
+	switch {
+	case c.n == 0:
+		return nil
+	case c.bitset != nil && c.n <= maxArrayLen:
+		array := make([]uint16, 0, c.n)
+		c.desc(func(v uint16) bool {
+			array = append(array, v)
+			return true
+		})
+
+		for i, j := 0, len(array)-1; i < j; i, j = i+1, j-1 {
+			array[i], array[j] = array[j], array[i]
+		}
+
+		c.array, c.bitset = array, nil
+	case c.bitset == nil && c.n > maxArrayLen:
+		c.toBitset()
+	}
+
+	return c
+}
+
+func and(x, y *container) *container {
+	if x == nil || y == nil {
+		return nil
+	}
+
+	if x.bitset != nil && y.bitset != nil {
+		return bitsetOp(x, y, func(a, b uint64) uint64 { return a & b })
+	}
+
+	if x.bitset != nil {
+		x, y = y, x
+	}
+
+	return filterArray(x, y.contains)
+}
+
+func andNot(x, y *container) *container {
+	if x == nil {
+		return nil
+	}
+
+	if y == nil {
+		return clone(x)
+	}
+
+	if x.bitset != nil && y.bitset != nil {
+		return bitsetOp(x, y, func(a, b uint64) uint64 { return a &^ b })
+	}
+
+	if x.bitset != nil {
+		result := clone(x)
+		for _, v := range y.array {
+			result.remove(v)
+		}
+
+		return result.normalize()
+	}
+
+	return filterArray(x, func(v uint16) bool { return !y.contains(v) })
+}
+
+func or(x, y *container) *container {
+	switch {
+	case x == nil && y == nil:
+		return nil
+	case x == nil:
+		return clone(y)
+	case y == nil:
+		return clone(x)
+	}
+
+	if x.bitset != nil || y.bitset != nil {
+		if x.bitset == nil {
+			x, y = y, x
+		}
+
+		result := clone(x)
+		y.desc(func(v uint16) bool {
+			result.add(v)
+			return true
+		})
+
+		return result
+	}
+
+	array := make([]uint16, 0, len(x.array)+len(y.array))
+	i, j := 0, 0
+	for i < len(x.array) || j < len(y.array) {
+		switch {
+		case j == len(y.array) || i < len(x.array) && x.array[i] < y.array[j]:
+			array = append(array, x.array[i])
+			i++
+		case i == len(x.array) || y.array[j] < x.array[i]:
+			array = append(array, y.array[j])
+			j++
+		default:
+			array = append(array, x.array[i])
+			i++
+			j++
+		}
+	}
+
+	return (&container{array: array, n: len(array)}).normalize()
+}
+
+// filterArray returns the values of the array container satisfying the predicate.
+func filterArray(c *container, predicate func(v uint16) bool) *container {
+	array := make([]uint16, 0)
+	for _, v := range c.array {
+		if predicate(v) {
+			array = append(array, v)
+		}
+	}
+
+	return (&container{array: array, n: len(array)}).normalize()
+}
+
+func bitsetOp(x, y *container, op func(a, b uint64) uint64) *container {
+	result := &container{bitset: new([bitsetWords]uint64)}
+	for w := range result.bitset {
+		result.bitset[w] = op(x.bitset[w], y.bitset[w])
+		result.n += bits.OnesCount64(result.bitset[w])
+	}
+
+	return result.normalize()
+}
+
+func clone(c *container) *container {
+	result := &container{n: c.n}
+	if c.bitset != nil {
+		bitset := *c.bitset
+		result.bitset = &bitset
+	} else {
+		result.array = append([]uint16(nil), c.array...)
+	}
+
+	return result
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
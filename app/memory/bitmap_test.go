@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(b *bitmap) []int32 {
+	result := []int32{}
+	b.Desc(func(id int32) bool {
+		result = append(result, id)
+		return true
+	})
+
+	return result
+}
+
+func Test_Bitmap(t *testing.T) {
+	a := bitmapOf(1, 64, 70000, 200000)
+	b := bitmapOf(64, 65, 200000)
+
+	assert.Equal(t, []int32{200000, 70000, 64, 1}, ids(a))
+	assert.Equal(t, 4, a.Count())
+	assert.True(t, a.Contains(70000))
+	assert.False(t, a.Contains(65))
+
+	assert.Equal(t, []int32{200000, 64}, ids(a.And(b)))
+	assert.Equal(t, []int32{70000, 1}, ids(a.AndNot(b)))
+	assert.Equal(t, []int32{200000, 70000, 65, 64, 1}, ids(a.Or(b)))
+
+	a.Remove(70000)
+	assert.Equal(t, []int32{200000, 64, 1}, ids(a))
+
+	var empty *bitmap
+	assert.Equal(t, 0, empty.Count())
+	assert.Equal(t, []int32{}, ids(a.And(empty)))
+	assert.Equal(t, []int32{200000, 64, 1}, ids(a.Or(empty)))
+}
+
+func Test_Bitmap_DescStops(t *testing.T) {
+	b := bitmapOf(3, 2, 1)
+
+	result := []int32{}
+	b.Desc(func(id int32) bool {
+		result = append(result, id)
+		return len(result) < 2
+	})
+
+	assert.Equal(t, []int32{3, 2}, result)
+}
+
+func Test_Bitmap_Containers(t *testing.T) {
+	// even ids up to 20000 make a bitset container, multiples of 3 an array one
+	even, triple := newBitmap(), newBitmap()
+	for id := int32(0); id < 20000; id += 2 {
+		even.Add(id)
+	}
+	for id := int32(0); id < 3000; id += 3 {
+		triple.Add(id)
+	}
+
+	assert.NotNil(t, even.containers[0].bitset)
+	assert.Nil(t, triple.containers[0].bitset)
+	assert.Equal(t, 10000, even.Count())
+	assert.Equal(t, 1000, triple.Count())
+
+	sixth := even.And(triple)
+	assert.Equal(t, 500, sixth.Count())
+	assert.Nil(t, sixth.containers[0].bitset)
+	assert.True(t, sixth.Contains(2994))
+	assert.False(t, sixth.Contains(2997))
+
+	assert.Equal(t, 10500, even.Or(triple).Count())
+	assert.Equal(t, 9500, even.AndNot(triple).Count())
+	assert.Equal(t, 500, triple.AndNot(even).Count())
+	assert.Equal(t, 0, even.AndNot(even).Count())
+
+	odd := newBitmap()
+	for id := int32(1); id < 20000; id += 2 {
+		odd.Add(id)
+	}
+
+	assert.Equal(t, 0, even.And(odd).Count())
+	assert.Equal(t, 20000, even.Or(odd).Count())
+	assert.Equal(t, []int32{19999, 19998, 19997}, ids(even.Or(odd))[:3])
+}
@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"time"
+)
+
+// dictColumn is a dictionary encoded column with a bitmap of accounts per code,
+// the accounts having null are kept under the code 0.
+type dictColumn struct {
+	dict  *dict
+	codes []uint32
+	index map[uint32]*bitmap
+}
+
+func newDictColumn() *dictColumn {
+	return &dictColumn{
+		dict:  newDict(),
+		index: make(map[uint32]*bitmap),
+	}
+}
+
+func (c *dictColumn) grow(size int) {
+	c.codes = growUint32(c.codes, size)
+}
+
+func (c *dictColumn) Set(id int32, value *string) {
+	c.index[c.codes[id]].Remove(id)
+
+	code := c.dict.Encode(value)
+	c.codes[id] = code
+	if c.index[code] == nil {
+		c.index[code] = newBitmap()
+	}
+
+	c.index[code].Add(id)
+}
+
+func (c *dictColumn) Get(id int32) *string {
+	return c.dict.Value(c.codes[id])
+}
+
+func (c *dictColumn) Code(id int32) uint32 {
+	return c.codes[id]
+}
+
+// Bitmap returns the accounts having the value, nil if there are none.
+func (c *dictColumn) Bitmap(value string) *bitmap {
+	code, ok := c.dict.Code(value)
+	if !ok {
+		return nil
+	}
+
+	return c.index[code]
+}
+
+func (c *dictColumn) Nulls() *bitmap {
+	return c.index[0]
+}
+
+// Any returns the accounts having any of the values.
+func (c *dictColumn) Any(values []string) *bitmap {
+	result := newBitmap()
+	for _, value := range values {
+		result = result.Or(c.Bitmap(value))
+	}
+
+	return result
+}
+
+// Matching returns the accounts which values satisfy the predicate.
+func (c *dictColumn) Matching(predicate func(value string) bool) *bitmap {
+	result := newBitmap()
+	for _, code := range c.dict.Codes(predicate) {
+		result = result.Or(c.index[code])
+	}
+
+	return result
+}
+
+// timeColumn keeps unix timestamps with a bitmap of accounts per year.
+type timeColumn struct {
+	values []int64
+	years  map[int]*bitmap
+}
+
+func newTimeColumn() *timeColumn {
+	return &timeColumn{
+		years: make(map[int]*bitmap),
+	}
+}
+
+func (c *timeColumn) grow(size int) {
+	c.values = growInt64(c.values, size)
+}
+
+func (c *timeColumn) Set(id int32, ts int64) {
+	c.years[year(c.values[id])].Remove(id)
+
+	c.values[id] = ts
+	y := year(ts)
+	if c.years[y] == nil {
+		c.years[y] = newBitmap()
+	}
+
+	c.years[y].Add(id)
+}
+
+func (c *timeColumn) Get(id int32) int64 {
+	return c.values[id]
+}
+
+func (c *timeColumn) Year(y int) *bitmap {
+	return c.years[y]
+}
+
+// setColumn keeps a set of dictionary encoded values per account (e.g. interests)
+// with a bitmap of accounts per value.
+type setColumn struct {
+	dict   *dict
+	values [][]uint32
+	index  map[uint32]*bitmap
+}
+
+func newSetColumn() *setColumn {
+	return &setColumn{
+		dict:  newDict(),
+		index: make(map[uint32]*bitmap),
+	}
+}
+
+func (c *setColumn) grow(size int) {
+	if size <= len(c.values) {
+		return
+	}
+
+	values := make([][]uint32, size)
+	copy(values, c.values)
+	c.values = values
+}
+
+func (c *setColumn) Set(id int32, values []string) {
+	for _, code := range c.values[id] {
+		c.index[code].Remove(id)
+	}
+
+	codes := make([]uint32, 0, len(values))
+	for i := range values {
+		code := c.dict.Encode(&values[i])
+		if c.index[code] == nil {
+			c.index[code] = newBitmap()
+		}
+
+		if !c.index[code].Contains(id) {
+			c.index[code].Add(id)
+			codes = append(codes, code)
+		}
+	}
+
+	c.values[id] = codes
+}
+
+func (c *setColumn) Codes(id int32) []uint32 {
+	return c.values[id]
+}
+
+func (c *setColumn) Value(code uint32) *string {
+	return c.dict.Value(code)
+}
+
+func (c *setColumn) Bitmap(value string) *bitmap {
+	code, ok := c.dict.Code(value)
+	if !ok {
+		return nil
+	}
+
+	return c.index[code]
+}
+
+func (c *setColumn) BitmapOf(code uint32) *bitmap {
+	return c.index[code]
+}
+
+func year(ts int64) int {
+	return time.Unix(ts, 0).UTC().Year()
+}
+
+// growSize returns the capacity of a column to keep the id, doubling it to amortize the copying.
+func growSize(capacity, size int) int {
+	if size <= capacity {
+		return capacity
+	}
+
+	return max(size, 2*capacity)
+}
+
+func growUint32(values []uint32, size int) []uint32 {
+	if size <= len(values) {
+		return values
+	}
+
+	grown := make([]uint32, size)
+	copy(grown, values)
+	return grown
+}
+
+func growInt64(values []int64, size int) []int64 {
+	if size <= len(values) {
+		return values
+	}
+
+	grown := make([]int64, size)
+	copy(grown, values)
+	return grown
+}
+
+func growString(values []string, size int) []string {
+	if size <= len(values) {
+		return values
+	}
+
+	grown := make([]string, size)
+	copy(grown, values)
+	return grown
+}
@@ -0,0 +1,57 @@
+package memory
+
+// dict encodes strings by codes, the code 0 stands for null.
+type dict struct {
+	codes  map[string]uint32
+	values []string
+}
+
+func newDict() *dict {
+	return &dict{
+		codes:  make(map[string]uint32),
+		values: []string{""},
+	}
+}
+
+// Encode returns the code of the value adding it if it's met the first time.
+func (d *dict) Encode(value *string) uint32 {
+	if value == nil {
+		return 0
+	}
+
+	if code, ok := d.codes[*value]; ok {
+		return code
+	}
+
+	code := uint32(len(d.values))
+	d.codes[*value] = code
+	d.values = append(d.values, *value)
+
+	return code
+}
+
+func (d *dict) Code(value string) (uint32, bool) {
+	code, ok := d.codes[value]
+	return code, ok
+}
+
+func (d *dict) Value(code uint32) *string {
+	if code == 0 {
+		return nil
+	}
+
+	value := d.values[code]
+	return &value
+}
+
+// Codes returns the codes of the values satisfying the predicate.
+func (d *dict) Codes(predicate func(value string) bool) []uint32 {
+	codes := make([]uint32, 0)
+	for code, value := range d.values[1:] {
+		if predicate(value) {
+			codes = append(codes, uint32(code+1))
+		}
+	}
+
+	return codes
+}
@@ -0,0 +1,276 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+var (
+	errUnsupportedPredicate = errors.New("unsupported predicate")
+)
+
+// condition is a predicate compiled against the storage: the bitmap of the matching accounts
+// or, if no index covers the predicate, the check of a single account.
+type condition struct {
+	bitmap *bitmap
+	check  func(id int32) bool
+}
+
+func (s *Storage) FilterAccounts(ctx context.Context, f *repository.Filter) (*domain.AccountsOut, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, err := s.plan(f)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []domain.AccountOut{}
+	p.Run(func(id int32) bool {
+		accounts = append(accounts, s.account(id, f.Columns()))
+		return f.Limit == 0 || len(accounts) < f.Limit
+	})
+
+	return &domain.AccountsOut{Accounts: accounts}, nil
+}
+
+// ExplainFilter runs the filter and describes how it was evaluated.
+func (s *Storage) ExplainFilter(ctx context.Context, f *repository.Filter) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, err := s.plan(f)
+	if err != nil {
+		return "", err
+	}
+
+	found := 0
+	p.Run(func(id int32) bool {
+		found++
+		return f.Limit == 0 || found < f.Limit
+	})
+
+	return p.Explain(f.Limit), nil
+}
+
+func (s *Storage) condition(p repository.Predicate) (condition, error) {
+	switch p.Column {
+	case repository.AccountSex, repository.AccountStatus, repository.AccountFirstname,
+		repository.AccountSurname, repository.CountryName, repository.CityName:
+		return s.dictCondition(s.dictColumns()[p.Column], p)
+	case repository.AccountEmail:
+		return s.emailCondition(p)
+	case repository.AccountPhone:
+		return s.phoneCondition(p)
+	case repository.AccountBirth:
+		return s.timeCondition(s.birth, p)
+	case repository.AccountJoined:
+		return s.timeCondition(s.joined, p)
+	case repository.AccountPremStart, repository.AccountPremEnd:
+		return s.premiumCondition(p)
+	case repository.InterestName:
+		return s.relatedCondition(s.interests.Bitmap, p)
+	case repository.LikesLikeeID:
+		return s.relatedCondition(func(value string) *bitmap {
+			var likee int32
+			if _, err := fmt.Sscan(value, &likee); err != nil || !s.ids.Contains(likee) {
+				return nil
+			}
+
+			return s.likersOf(likee)
+		}, p)
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) dictColumns() map[string]*dictColumn {
+	return map[string]*dictColumn{
+		repository.AccountSex:       s.sex,
+		repository.AccountStatus:    s.status,
+		repository.AccountFirstname: s.fname,
+		repository.AccountSurname:   s.sname,
+		repository.CountryName:      s.country,
+		repository.CityName:         s.city,
+	}
+}
+
+func (s *Storage) dictCondition(c *dictColumn, p repository.Predicate) (condition, error) {
+	switch p.Op {
+	case repository.OpEq:
+		return condition{bitmap: c.Bitmap(str(p.Values[0]))}, nil
+	case repository.OpNeq:
+		return condition{bitmap: s.ids.AndNot(c.Bitmap(str(p.Values[0]))).AndNot(c.Nulls())}, nil
+	case repository.OpAny:
+		return condition{bitmap: c.Any(strs(p.Values))}, nil
+	case repository.OpNull:
+		return s.nullCondition(c.Nulls(), p.Values[0].(bool)), nil
+	case repository.OpStarts:
+		prefix := str(p.Values[0])
+		return condition{bitmap: c.Matching(func(value string) bool {
+			return strings.HasPrefix(value, prefix)
+		})}, nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) emailCondition(p repository.Predicate) (condition, error) {
+	value := str(p.Values[0])
+	switch p.Op {
+	case repository.OpEq:
+		return condition{check: func(id int32) bool { return s.email[id] == value }}, nil
+	case repository.OpLt:
+		return condition{check: func(id int32) bool { return s.email[id] < value }}, nil
+	case repository.OpGt:
+		return condition{check: func(id int32) bool { return s.email[id] > value }}, nil
+	case repository.OpDomain:
+		return condition{bitmap: s.domain.Bitmap(value)}, nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) phoneCondition(p repository.Predicate) (condition, error) {
+	switch p.Op {
+	case repository.OpEq:
+		value := str(p.Values[0])
+		return condition{check: func(id int32) bool { return s.phone[id] == value }}, nil
+	case repository.OpCode:
+		return condition{bitmap: s.phoneCode.Bitmap(str(p.Values[0]))}, nil
+	case repository.OpNull:
+		return s.nullCondition(s.phoneCode.Nulls(), p.Values[0].(bool)), nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) timeCondition(c *timeColumn, p repository.Predicate) (condition, error) {
+	if p.Op == repository.OpYear {
+		year, ok := p.Values[0].(int)
+		if !ok {
+			return condition{}, errUnsupportedPredicate
+		}
+
+		return condition{bitmap: c.Year(year)}, nil
+	}
+
+	value, ok := unix(p.Values[0])
+	if !ok {
+		return condition{}, errUnsupportedPredicate
+	}
+
+	switch p.Op {
+	case repository.OpEq:
+		return condition{check: func(id int32) bool { return c.Get(id) == value }}, nil
+	case repository.OpLt:
+		return condition{check: func(id int32) bool { return c.Get(id) < value }}, nil
+	case repository.OpGt:
+		return condition{check: func(id int32) bool { return c.Get(id) > value }}, nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) premiumCondition(p repository.Predicate) (condition, error) {
+	switch p.Op {
+	case repository.OpNull:
+		return s.nullCondition(s.ids.AndNot(s.premium), p.Values[0].(bool)), nil
+	case repository.OpNow:
+		now := p.Values[0].(time.Time).Unix()
+		active := p.Values[1].(bool)
+		return condition{check: func(id int32) bool { return s.premiumActive(id, now) == active }}, nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+// relatedCondition matches accounts related to every value (e.g. having all the interests)
+// or to any of them, related returns the accounts related to a value.
+func (s *Storage) relatedCondition(related func(value string) *bitmap, p repository.Predicate) (condition, error) {
+	values := strs(p.Values)
+	switch p.Op {
+	case repository.OpContains:
+		bitmaps := make([]*bitmap, 0, len(values))
+		for _, value := range values {
+			bitmaps = append(bitmaps, related(value))
+		}
+
+		// the rarest value first, so that the intersection shrinks quickly
+		sort.Slice(bitmaps, func(i, j int) bool {
+			return bitmaps[i].Count() < bitmaps[j].Count()
+		})
+
+		result := s.ids
+		for _, b := range bitmaps {
+			if result = result.And(b); result.Count() == 0 {
+				break
+			}
+		}
+
+		return condition{bitmap: result}, nil
+	case repository.OpAny:
+		result := newBitmap()
+		for _, value := range values {
+			result = result.Or(related(value))
+		}
+
+		return condition{bitmap: result}, nil
+	}
+
+	return condition{}, errUnsupportedPredicate
+}
+
+func (s *Storage) nullCondition(nulls *bitmap, isNull bool) condition {
+	if isNull {
+		return condition{bitmap: nulls}
+	}
+
+	return condition{bitmap: s.ids.AndNot(nulls)}
+}
+
+// likersOf returns the accounts which liked the account.
+func (s *Storage) likersOf(id int32) *bitmap {
+	result := newBitmap()
+	for _, l := range s.likers[id] {
+		result.Add(l.id)
+	}
+
+	return result
+}
+
+func (s *Storage) premiumActive(id int32, now int64) bool {
+	return s.premium.Contains(id) && s.premStart[id] <= now && s.premEnd[id] >= now
+}
+
+func str(value interface{}) string {
+	return fmt.Sprint(value)
+}
+
+func strs(values []interface{}) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, str(value))
+	}
+
+	return result
+}
+
+// unix converts the values compared with timestamp columns to unix seconds.
+func unix(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Unix(), true
+	case int64:
+		return v, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+const maxGroupKeys = 5
+
+// groupKey keeps the codes of the key values in the order of the keys.
+type groupKey [maxGroupKeys]uint32
+
+func (s *Storage) GroupAccounts(ctx context.Context, f *repository.Filter, keys []string, asc bool) (*domain.GroupsOut, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(keys) > maxGroupKeys {
+		return nil, domain.NewInternalError(errUnsupportedPredicate)
+	}
+
+	columns := make([]*dictColumn, len(keys))
+	interestsKey := -1
+	for i, key := range keys {
+		switch key {
+		case repository.AccountSex:
+			columns[i] = s.sex
+		case repository.AccountStatus:
+			columns[i] = s.status
+		case repository.CountryName:
+			columns[i] = s.country
+		case repository.CityName:
+			columns[i] = s.city
+		case repository.InterestName:
+			interestsKey = i
+		default:
+			return nil, domain.NewInternalError(errUnsupportedPredicate)
+		}
+	}
+
+	p, err := s.plan(f)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[groupKey]int64)
+	p.Run(func(id int32) bool {
+		var key groupKey
+		for i, c := range columns {
+			if c != nil {
+				key[i] = c.Code(id)
+			}
+		}
+
+		if interestsKey == -1 {
+			counts[key]++
+			return true
+		}
+
+		// an account is counted in the group of each of its interests, as joining the interests does
+		for _, code := range s.interests.Codes(id) {
+			key[interestsKey] = code
+			counts[key]++
+		}
+
+		return true
+	})
+
+	rows := make([]groupRow, 0, len(counts))
+	for key, count := range counts {
+		row := groupRow{
+			group:  domain.GroupOut{Count: count},
+			values: make([]*string, len(keys)),
+		}
+
+		for i, k := range keys {
+			if i == interestsKey {
+				row.values[i] = s.interests.Value(key[i])
+			} else {
+				row.values[i] = columns[i].dict.Value(key[i])
+			}
+
+			setGroupValue(&row.group, k, row.values[i])
+		}
+
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if asc {
+			return rows[i].less(rows[j])
+		}
+
+		return rows[j].less(rows[i])
+	})
+
+	groups := make([]domain.GroupOut, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, row.group)
+	}
+
+	if f.Limit != 0 && len(groups) > f.Limit {
+		groups = groups[:f.Limit]
+	}
+
+	return &domain.GroupsOut{Groups: groups}, nil
+}
+
+func setGroupValue(group *domain.GroupOut, key string, value *string) {
+	switch key {
+	case repository.AccountSex:
+		group.Sex = value
+	case repository.AccountStatus:
+		group.Status = value
+	case repository.InterestName:
+		group.Interests = value
+	case repository.CountryName:
+		group.Country = value
+	case repository.CityName:
+		group.City = value
+	}
+}
+
+type groupRow struct {
+	group  domain.GroupOut
+	values []*string
+}
+
+// less orders the groups by the count and then by the key values compared byte by byte,
+// as Postgres does with the C collation of the group query, null is greater than any value.
+func (r groupRow) less(other groupRow) bool {
+	if r.group.Count != other.group.Count {
+		return r.group.Count < other.group.Count
+	}
+
+	for i := range r.values {
+		x, y := r.values[i], other.values[i]
+		switch {
+		case x == nil && y == nil:
+			continue
+		case x == nil:
+			return false
+		case y == nil:
+			return true
+		case *x != *y:
+			return *x < *y
+		}
+	}
+
+	return false
+}
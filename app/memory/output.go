@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+var (
+	recommendColumns = columnsOf(repository.AccountStatus, repository.AccountFirstname,
+		repository.AccountSurname, repository.AccountBirth, repository.AccountPremStart)
+	suggestColumns = columnsOf(repository.AccountStatus, repository.AccountFirstname, repository.AccountSurname)
+)
+
+// account returns id, email and the fields of the given columns,
+// as repository.Repository does.
+func (s *Storage) account(id int32, columns map[string]struct{}) domain.AccountOut {
+	a := domain.AccountOut{
+		ID:    id,
+		Email: s.email[id],
+	}
+
+	for column := range columns {
+		switch column {
+		case repository.AccountSex:
+			a.Sex = *s.sex.Get(id)
+		case repository.AccountStatus:
+			a.Status = *s.status.Get(id)
+		case repository.AccountFirstname:
+			a.Fname = s.fname.Get(id)
+		case repository.AccountSurname:
+			a.Sname = s.sname.Get(id)
+		case repository.AccountPhone:
+			if s.phone[id] != "" {
+				phone := s.phone[id]
+				a.Phone = &phone
+			}
+		case repository.AccountBirth:
+			a.Birth = s.birth.Get(id)
+		case repository.CountryName:
+			a.Country = s.country.Get(id)
+		case repository.CityName:
+			a.City = s.city.Get(id)
+		case repository.AccountPremStart:
+			if s.premium.Contains(id) {
+				a.Premium = &domain.PremiumOut{Start: s.premStart[id], Finish: s.premEnd[id]}
+			}
+		}
+	}
+
+	return a
+}
+
+func columnsOf(columns ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		set[column] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+// probeLimit is the number of candidates below which the remaining bitmaps are probed
+// for every candidate instead of being intersected with the candidates.
+const probeLimit = 4096
+
+const (
+	actionIntersect = "intersect"
+	actionProbe     = "probe"
+	actionCheck     = "check"
+	actionSkip      = "skip"
+)
+
+type planStep struct {
+	param     string
+	estimated float64
+	condition condition
+	// count is the number of the accounts in the bitmap, -1 for checks
+	count      int
+	action     string
+	candidates int
+}
+
+// selectivity is the actual share of the accounts in the bitmap, checks have only the estimate.
+func (step *planStep) selectivity(total int) float64 {
+	if step.count < 0 || total == 0 {
+		return step.estimated
+	}
+
+	return float64(step.count) / float64(total)
+}
+
+// plan evaluates the filter: the bitmaps are intersected from the most selective one
+// while there are many candidates, then the candidates are iterated in the descending order
+// and checked against the rest of the predicates.
+type plan struct {
+	total      int
+	steps      []*planStep
+	candidates *bitmap
+	checks     []func(id int32) bool
+	scanned    int
+	matched    int
+}
+
+// plan compiles the steps of the filter, the selectivities estimated by the service
+// are refined with the actual cardinalities of the bitmaps.
+func (s *Storage) plan(f *repository.Filter) (*plan, error) {
+	p := &plan{total: s.ids.Count(), candidates: s.ids}
+	for _, step := range f.Steps() {
+		for _, predicate := range step.Predicates {
+			c, err := s.condition(predicate)
+			if err != nil {
+				return nil, domain.NewInternalError(fmt.Errorf("%w: %s %s", err, predicate.Column, predicate.Op))
+			}
+
+			ps := &planStep{param: step.Param, estimated: step.Selectivity, condition: c, count: -1}
+			if c.check == nil {
+				ps.count = c.bitmap.Count()
+			}
+
+			p.steps = append(p.steps, ps)
+		}
+	}
+
+	sort.SliceStable(p.steps, func(i, j int) bool {
+		a, b := p.steps[i], p.steps[j]
+		if (a.count < 0) != (b.count < 0) {
+			return b.count < 0
+		}
+
+		return a.selectivity(p.total) < b.selectivity(p.total)
+	})
+
+	intersected := false
+	for _, step := range p.steps {
+		switch {
+		case step.count < 0:
+			step.action = actionCheck
+			p.checks = append(p.checks, step.condition.check)
+		case intersected && p.candidates.Count() == 0:
+			step.action = actionSkip
+		case intersected && p.candidates.Count() <= probeLimit:
+			step.action = actionProbe
+			p.checks = append(p.checks, step.condition.bitmap.Contains)
+		default:
+			step.action = actionIntersect
+			p.candidates = p.candidates.And(step.condition.bitmap)
+			intersected = true
+		}
+
+		step.candidates = p.candidates.Count()
+	}
+
+	return p, nil
+}
+
+// Run calls fn for the matching accounts in the descending order of ids until fn returns false.
+func (p *plan) Run(fn func(id int32) bool) {
+	p.candidates.Desc(func(id int32) bool {
+		p.scanned++
+		for _, check := range p.checks {
+			if !check(id) {
+				return true
+			}
+		}
+
+		p.matched++
+		return fn(id)
+	})
+}
+
+// Match checks a single account, it's used when the candidates are selected by other means than the filter.
+func (p *plan) Match(id int32) bool {
+	if !p.candidates.Contains(id) {
+		return false
+	}
+
+	for _, check := range p.checks {
+		if !check(id) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Explain describes the steps and the statistics of the run.
+func (p *plan) Explain(limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "filter of %d accounts, limit %d\n", p.total, limit)
+	for i, step := range p.steps {
+		fmt.Fprintf(&b, "%3d. %-9s %-20s estimated %7.3f%%", i+1, step.action, step.param, 100*step.estimated)
+		if step.count >= 0 {
+			fmt.Fprintf(&b, "  actual %7.3f%%", 100*step.selectivity(p.total))
+		}
+		if step.action == actionIntersect {
+			fmt.Fprintf(&b, "  -> %d candidates", step.candidates)
+		}
+
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "scan descending: %d scanned, %d matched\n", p.scanned, p.matched)
+
+	return b.String()
+}
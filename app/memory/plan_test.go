@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app/repository"
+	"accounts/domain"
+	"accounts/util"
+)
+
+// testStorage has 100 accounts: the odd ones are men, every tenth one lives in Москва.
+func testStorage(t *testing.T) *Storage {
+	s := New()
+	birth := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	joined := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	for id := int32(1); id <= 100; id++ {
+		sex := domain.SexFemale
+		if id%2 == 1 {
+			sex = domain.SexMale
+		}
+
+		a := domain.AccountInput{
+			ID:     (*domain.FieldID)(util.PtrInt32(id)),
+			Email:  (*domain.FieldEmail)(util.PtrString(fmt.Sprintf("%d@test.ru", id))),
+			Sex:    (*domain.FieldSex)(util.PtrString(sex)),
+			Birth:  (*domain.FieldBirth)(util.PtrInt64(birth + int64(id))),
+			Joined: (*domain.FieldJoined)(util.PtrInt64(joined)),
+			Status: (*domain.FieldStatus)(util.PtrString(domain.StatusFree)),
+		}
+		if id%10 == 0 {
+			a.City = (*domain.FieldCity)(util.PtrString("Москва"))
+		}
+
+		require.NoError(t, a.Validate())
+		require.NoError(t, s.AddAccount(context.Background(), a))
+	}
+
+	return s
+}
+
+func Test_Plan_MostSelectiveFirst(t *testing.T) {
+	s := testStorage(t)
+
+	f := repository.NewFilter()
+	f.Step("sex_eq", 0.5)
+	f.Eq(repository.AccountSex, domain.SexFemale)
+	f.Step("birth_lt", 0.5)
+	f.Lt(repository.AccountBirth, time.Date(1990, 1, 1, 0, 0, 50, 0, time.UTC))
+	f.Step("city_eq", 0.002)
+	f.Eq(repository.CityName, "Москва")
+	f.Limit = 2
+
+	p, err := s.plan(f)
+	require.NoError(t, err)
+
+	steps := make([]string, 0, len(p.steps))
+	for _, step := range p.steps {
+		steps = append(steps, step.action+" "+step.param)
+	}
+
+	// the city bitmap is the smallest, the few candidates left are probed against the sex bitmap
+	assert.Equal(t, []string{"intersect city_eq", "probe sex_eq", "check birth_lt"}, steps)
+	assert.Equal(t, 10, p.steps[0].candidates)
+
+	matched := []int32{}
+	p.Run(func(id int32) bool {
+		matched = append(matched, id)
+		return len(matched) < f.Limit
+	})
+
+	assert.Equal(t, []int32{40, 30}, matched)
+	// 100, 90, 80, 70, 60 and 50 fail the birth check
+	assert.Equal(t, 8, p.scanned)
+}
+
+func Test_Plan_EmptyIntersection(t *testing.T) {
+	s := testStorage(t)
+
+	f := repository.NewFilter()
+	f.Step("city_eq", 0.002)
+	f.Eq(repository.CityName, "Париж")
+	f.Step("sex_eq", 0.5)
+	f.Eq(repository.AccountSex, domain.SexMale)
+
+	p, err := s.plan(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, actionIntersect, p.steps[0].action)
+	assert.Equal(t, actionSkip, p.steps[1].action)
+	assert.Equal(t, 0, p.candidates.Count())
+}
+
+func Test_Storage_ExplainFilter(t *testing.T) {
+	s := testStorage(t)
+
+	f := repository.NewFilter()
+	f.Step("city_eq", 0.002)
+	f.Eq(repository.CityName, "Москва")
+	f.Limit = 3
+
+	explain, err := s.ExplainFilter(context.Background(), f)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(explain), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "filter of 100 accounts, limit 3", lines[0])
+	assert.Contains(t, lines[1], "intersect city_eq")
+	assert.Contains(t, lines[1], "actual  10.000%")
+	assert.Contains(t, lines[1], "-> 10 candidates")
+	assert.Equal(t, "scan descending: 3 scanned, 3 matched", lines[2])
+}
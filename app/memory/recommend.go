@@ -0,0 +1,189 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+// RecommendAccounts ranks the accounts of the opposite sex having common interests with the target
+// by active premium, status, the number of common interests, the age difference and id.
+func (s *Storage) RecommendAccounts(ctx context.Context, id int32, f *repository.Filter, now time.Time) (*domain.AccountsOut, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.ids.Contains(id) {
+		return nil, repository.ErrNotFound
+	}
+
+	p, err := s.plan(f)
+	if err != nil {
+		return nil, err
+	}
+
+	common := make(map[int32]int)
+	sex := s.sex.Code(id)
+	for _, code := range s.interests.Codes(id) {
+		s.interests.BitmapOf(code).Desc(func(other int32) bool {
+			if s.sex.Code(other) != sex && p.Match(other) {
+				common[other]++
+			}
+
+			return true
+		})
+	}
+
+	type candidate struct {
+		id      int32
+		premium bool
+		status  int
+		common  int
+		ageDiff int64
+	}
+
+	ts := now.Unix()
+	birth := s.birth.Get(id)
+	candidates := make([]candidate, 0, len(common))
+	for other, count := range common {
+		candidates = append(candidates, candidate{
+			id:      other,
+			premium: s.premiumActive(other, ts),
+			status:  statusRank(*s.status.Get(other)),
+			common:  count,
+			ageDiff: abs(s.birth.Get(other) - birth),
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch {
+		case a.premium != b.premium:
+			return a.premium
+		case a.status != b.status:
+			return a.status < b.status
+		case a.common != b.common:
+			return a.common > b.common
+		case a.ageDiff != b.ageDiff:
+			return a.ageDiff < b.ageDiff
+		}
+
+		return a.id < b.id
+	})
+
+	accounts := []domain.AccountOut{}
+	for _, c := range candidates {
+		if f.Limit != 0 && len(accounts) == f.Limit {
+			break
+		}
+
+		accounts = append(accounts, s.account(c.id, recommendColumns))
+	}
+
+	return &domain.AccountsOut{Accounts: accounts}, nil
+}
+
+// SuggestAccounts ranks the accounts liked by the similar accounts the way buildSuggestQuery
+// of the repository does, the similarity is described there.
+func (s *Storage) SuggestAccounts(ctx context.Context, id int32, f *repository.Filter) (*domain.AccountsOut, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.ids.Contains(id) {
+		return nil, repository.ErrNotFound
+	}
+
+	p, err := s.plan(f)
+	if err != nil {
+		return nil, err
+	}
+
+	sex := s.sex.Code(id)
+	liked := averageLikes(s.likes[id])
+	similarity := make(map[int32]float64)
+	for likee, ts := range liked {
+		for liker, likerTs := range averageLikes(s.likers[likee]) {
+			if liker == id || s.sex.Code(liker) != sex || !p.Match(liker) {
+				continue
+			}
+
+			similarity[liker] += 1 / math.Max(math.Abs(likerTs-ts), 1)
+		}
+	}
+
+	rank := make(map[int32]float64)
+	for liker, sim := range similarity {
+		for _, l := range s.likes[liker] {
+			if _, ok := liked[l.id]; ok {
+				continue
+			}
+
+			if r, ok := rank[l.id]; !ok || sim > r {
+				rank[l.id] = sim
+			}
+		}
+	}
+
+	suggested := make([]int32, 0, len(rank))
+	for other := range rank {
+		suggested = append(suggested, other)
+	}
+
+	sort.Slice(suggested, func(i, j int) bool {
+		a, b := suggested[i], suggested[j]
+		if rank[a] != rank[b] {
+			return rank[a] > rank[b]
+		}
+
+		return a > b
+	})
+
+	if f.Limit != 0 && len(suggested) > f.Limit {
+		suggested = suggested[:f.Limit]
+	}
+
+	accounts := make([]domain.AccountOut, 0, len(suggested))
+	for _, other := range suggested {
+		accounts = append(accounts, s.account(other, suggestColumns))
+	}
+
+	return &domain.AccountsOut{Accounts: accounts}, nil
+}
+
+// averageLikes returns the average timestamp of the likes per account.
+func averageLikes(likes []like) map[int32]float64 {
+	sums := make(map[int32]float64, len(likes))
+	counts := make(map[int32]int, len(likes))
+	for _, l := range likes {
+		sums[l.id] += float64(l.ts)
+		counts[l.id]++
+	}
+
+	for id, count := range counts {
+		sums[id] /= float64(count)
+	}
+
+	return sums
+}
+
+func statusRank(status string) int {
+	switch status {
+	case domain.StatusFree:
+		return 0
+	case domain.StatusComplicated:
+		return 1
+	}
+
+	return 2
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
@@ -0,0 +1,390 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"accounts/app/repository"
+	"accounts/domain"
+)
+
+// DefaultMaxID bounds the ids of the accounts, the columns are indexed by the id
+// and take about 170 bytes per id up to the greatest one.
+const DefaultMaxID = 1 << 22
+
+var (
+	errDuplicateID  = domain.NewConflictError(errors.New("duplicate id"))
+	errIDOutOfRange = domain.NewValidationError(errors.New("id is out of range"))
+)
+
+type like struct {
+	id int32
+	ts int64
+}
+
+// Storage keeps the accounts in memory in columns indexed by the account id,
+// the values filtered by are indexed by bitmaps. It produces the same results as repository.Repository.
+type Storage struct {
+	mu       sync.RWMutex
+	capacity int
+	maxID    int32
+
+	ids       *bitmap
+	email     []string
+	phone     []string
+	sex       *dictColumn
+	status    *dictColumn
+	fname     *dictColumn
+	sname     *dictColumn
+	country   *dictColumn
+	city      *dictColumn
+	domain    *dictColumn // domains of the emails
+	phoneCode *dictColumn
+	birth     *timeColumn
+	joined    *timeColumn
+	premStart []int64
+	premEnd   []int64
+	premium   *bitmap // accounts having premium
+	interests *setColumn
+	likes     [][]like // likes of the account
+	likers    [][]like // likes of the account by the others
+
+	emails map[string]int32
+	phones map[string]int32
+}
+
+func New() *Storage {
+	return &Storage{
+		maxID:     DefaultMaxID,
+		ids:       newBitmap(),
+		sex:       newDictColumn(),
+		status:    newDictColumn(),
+		fname:     newDictColumn(),
+		sname:     newDictColumn(),
+		country:   newDictColumn(),
+		city:      newDictColumn(),
+		domain:    newDictColumn(),
+		phoneCode: newDictColumn(),
+		birth:     newTimeColumn(),
+		joined:    newTimeColumn(),
+		premium:   newBitmap(),
+		interests: newSetColumn(),
+		emails:    make(map[string]int32),
+		phones:    make(map[string]int32),
+	}
+}
+
+// WithMaxID sets the greatest id of the accounts, the greater ones are rejected
+// as the columns would be allocated up to them.
+func (s *Storage) WithMaxID(id int32) *Storage {
+	s.maxID = id
+	return s
+}
+
+func (s *Storage) AddAccount(ctx context.Context, a domain.AccountInput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int32(*a.ID)
+	if err := s.checkIDs(a); err != nil {
+		return err
+	}
+
+	if err := s.checkDuplicates(id, (*string)(a.Email), (*string)(a.Phone)); err != nil {
+		return err
+	}
+
+	if s.ids.Contains(id) {
+		return errDuplicateID
+	}
+
+	if err := s.checkLikes(id, a.Likes); err != nil {
+		return err
+	}
+
+	s.insert(a)
+
+	return nil
+}
+
+// HasAccounts tells whether any account is stored.
+func (s *Storage) HasAccounts(ctx context.Context) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ids.Count() > 0, nil
+}
+
+// LoadAccounts inserts a batch of the snapshot accounts checking only the range of the ids,
+// the likes may refer to the accounts of the later batches.
+func (s *Storage) LoadAccounts(ctx context.Context, accounts []domain.AccountInput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range accounts {
+		if err := s.checkIDs(a); err != nil {
+			return err
+		}
+
+		s.insert(a)
+	}
+
+	return nil
+}
+
+func (s *Storage) insert(a domain.AccountInput) {
+	id := int32(*a.ID)
+	s.grow(id)
+	s.ids.Add(id)
+	s.setEmail(id, string(*a.Email))
+	s.setPhone(id, (*string)(a.Phone))
+	s.sex.Set(id, (*string)(a.Sex))
+	s.status.Set(id, (*string)(a.Status))
+	s.fname.Set(id, (*string)(a.Name))
+	s.sname.Set(id, (*string)(a.Surname))
+	s.country.Set(id, (*string)(a.Country))
+	s.city.Set(id, (*string)(a.City))
+	s.birth.Set(id, int64(*a.Birth))
+	s.joined.Set(id, int64(*a.Joined))
+	s.setPremium(id, a.Premium)
+	s.interests.Set(id, interestValues(a.Interests))
+	s.addLikes(id, a.Likes)
+}
+
+func (s *Storage) UpdateAccount(ctx context.Context, a domain.AccountUpdate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := int32(a.ID)
+	if !s.ids.Contains(id) {
+		return repository.ErrNotFound
+	}
+
+	if err := s.checkDuplicates(id, (*string)(a.Email), (*string)(a.Phone)); err != nil {
+		return err
+	}
+
+	if err := s.checkLikes(id, a.Likes); err != nil {
+		return err
+	}
+
+	if a.Email != nil {
+		s.setEmail(id, string(*a.Email))
+	}
+	if a.Phone != nil {
+		s.setPhone(id, (*string)(a.Phone))
+	}
+	if a.Sex != nil {
+		s.sex.Set(id, (*string)(a.Sex))
+	}
+	if a.Status != nil {
+		s.status.Set(id, (*string)(a.Status))
+	}
+	if a.Name != nil {
+		s.fname.Set(id, (*string)(a.Name))
+	}
+	if a.Surname != nil {
+		s.sname.Set(id, (*string)(a.Surname))
+	}
+	if a.Country != nil {
+		s.country.Set(id, (*string)(a.Country))
+	}
+	if a.City != nil {
+		s.city.Set(id, (*string)(a.City))
+	}
+	if a.Birth != nil {
+		s.birth.Set(id, int64(*a.Birth))
+	}
+	if a.Joined != nil {
+		s.joined.Set(id, int64(*a.Joined))
+	}
+	if a.Premium != nil {
+		s.setPremium(id, a.Premium)
+	}
+	if a.Interests != nil {
+		s.interests.Set(id, interestValues(a.Interests))
+	}
+
+	s.addLikes(id, a.Likes)
+
+	return nil
+}
+
+func (s *Storage) AddLikes(ctx context.Context, likes *domain.LikesInput) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range likes.AccountIDs() {
+		if !s.ids.Contains(id) {
+			return repository.ErrUnknownAccount
+		}
+	}
+
+	for _, l := range likes.Likes {
+		s.addLike(int32(*l.Liker), int32(*l.Likee), int64(*l.Timestamp))
+	}
+
+	return nil
+}
+
+// DatasetStats counts the accounts, their likes and their interests.
+func (s *Storage) DatasetStats(ctx context.Context) (repository.DatasetStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := repository.DatasetStats{Accounts: int64(s.ids.Count())}
+	s.ids.Desc(func(id int32) bool {
+		stats.Likes += int64(len(s.likes[id]))
+		stats.Interests += int64(len(s.interests.Codes(id)))
+		return true
+	})
+
+	return stats, nil
+}
+
+// checkIDs returns a validation error if the id of the account or of a liked one exceeds maxID.
+func (s *Storage) checkIDs(a domain.AccountInput) error {
+	if int32(*a.ID) > s.maxID {
+		return errIDOutOfRange
+	}
+
+	for _, l := range a.Likes {
+		if int32(*l.UserID) > s.maxID {
+			return errIDOutOfRange
+		}
+	}
+
+	return nil
+}
+
+// checkDuplicates returns a conflict if another account has the same email or phone.
+func (s *Storage) checkDuplicates(id int32, email, phone *string) error {
+	if email != nil {
+		if other, ok := s.emails[*email]; ok && other != id {
+			return repository.ErrDuplicateEmail
+		}
+	}
+
+	if phone != nil {
+		if other, ok := s.phones[*phone]; ok && other != id {
+			return repository.ErrDuplicatePhone
+		}
+	}
+
+	return nil
+}
+
+// checkLikes returns an error if any of the liked accounts doesn't exist, the account itself may be liked.
+func (s *Storage) checkLikes(id int32, likes []*domain.AccountLikeInput) error {
+	for _, l := range likes {
+		likee := int32(*l.UserID)
+		if likee != id && !s.ids.Contains(likee) {
+			return repository.ErrUnknownAccount
+		}
+	}
+
+	return nil
+}
+
+// grow makes room for the id in every column.
+func (s *Storage) grow(id int32) {
+	size := growSize(s.capacity, int(id)+1)
+	if size == s.capacity {
+		return
+	}
+
+	s.capacity = size
+	s.email = growString(s.email, size)
+	s.phone = growString(s.phone, size)
+	for _, c := range []*dictColumn{s.sex, s.status, s.fname, s.sname, s.country, s.city, s.domain, s.phoneCode} {
+		c.grow(size)
+	}
+	s.birth.grow(size)
+	s.joined.grow(size)
+	s.premStart = growInt64(s.premStart, size)
+	s.premEnd = growInt64(s.premEnd, size)
+	s.interests.grow(size)
+	s.likes = growLikes(s.likes, size)
+	s.likers = growLikes(s.likers, size)
+}
+
+func (s *Storage) setEmail(id int32, email string) {
+	delete(s.emails, s.email[id])
+	s.emails[email] = id
+	s.email[id] = email
+
+	emailDomain := email[strings.LastIndex(email, "@")+1:]
+	s.domain.Set(id, &emailDomain)
+}
+
+func (s *Storage) setPhone(id int32, phone *string) {
+	if s.phone[id] != "" {
+		delete(s.phones, s.phone[id])
+	}
+
+	if phone == nil {
+		s.phone[id] = ""
+		s.phoneCode.Set(id, nil)
+		return
+	}
+
+	s.phones[*phone] = id
+	s.phone[id] = *phone
+
+	code := phoneCode(*phone)
+	s.phoneCode.Set(id, &code)
+}
+
+func (s *Storage) setPremium(id int32, p *domain.PremiumInput) {
+	if p == nil {
+		return
+	}
+
+	s.premStart[id] = int64(*p.Start)
+	s.premEnd[id] = int64(*p.End)
+	s.premium.Add(id)
+}
+
+func (s *Storage) addLikes(id int32, likes []*domain.AccountLikeInput) {
+	for _, l := range likes {
+		s.addLike(id, int32(*l.UserID), int64(*l.Timestamp))
+	}
+}
+
+func (s *Storage) addLike(liker, likee int32, ts int64) {
+	s.grow(likee)
+	s.likes[liker] = append(s.likes[liker], like{id: likee, ts: ts})
+	s.likers[likee] = append(s.likers[likee], like{id: liker, ts: ts})
+}
+
+// phoneCode returns the code in the parentheses of the phone, e.g. 923 of 8(923)1234567.
+func phoneCode(phone string) string {
+	start := strings.Index(phone, "(")
+	end := strings.Index(phone, ")")
+	if start == -1 || end < start {
+		return ""
+	}
+
+	return phone[start+1 : end]
+}
+
+func interestValues(interests []*domain.FieldInterest) []string {
+	values := make([]string, 0, len(interests))
+	for _, interest := range interests {
+		values = append(values, string(*interest))
+	}
+
+	return values
+}
+
+func growLikes(values [][]like, size int) [][]like {
+	if size <= len(values) {
+		return values
+	}
+
+	grown := make([][]like, size)
+	copy(grown, values)
+	return grown
+}
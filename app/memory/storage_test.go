@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/domain"
+	"accounts/util"
+)
+
+func testAccount(id int32) domain.AccountInput {
+	return domain.AccountInput{
+		ID:     (*domain.FieldID)(util.PtrInt32(id)),
+		Email:  (*domain.FieldEmail)(util.PtrString("max@test.ru")),
+		Sex:    (*domain.FieldSex)(util.PtrString(domain.SexMale)),
+		Birth:  (*domain.FieldBirth)(util.PtrInt64(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Unix())),
+		Joined: (*domain.FieldJoined)(util.PtrInt64(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Unix())),
+		Status: (*domain.FieldStatus)(util.PtrString(domain.StatusFree)),
+	}
+}
+
+func Test_AddAccount_IDOutOfRange(t *testing.T) {
+	s := New()
+
+	a := testAccount(math.MaxInt32)
+	require.NoError(t, a.Validate())
+	err := s.AddAccount(context.Background(), a)
+	assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+	assert.Zero(t, s.capacity, "the columns mustn't grow for a rejected id")
+
+	s = New().WithMaxID(100)
+	assert.Equal(t, domain.ErrorValidation, domain.KindOf(s.AddAccount(context.Background(), testAccount(101))))
+	require.NoError(t, s.AddAccount(context.Background(), testAccount(100)))
+	assert.True(t, s.ids.Contains(100))
+}
+
+func Test_LoadAccounts_IDOutOfRange(t *testing.T) {
+	s := New().WithMaxID(100)
+
+	liker := testAccount(1)
+	liker.Likes = []*domain.AccountLikeInput{{
+		UserID:    (*domain.FieldID)(util.PtrInt32(math.MaxInt32)),
+		Timestamp: (*domain.FieldTimestamp)(util.PtrInt64(1500000000)),
+	}}
+
+	err := s.LoadAccounts(context.Background(), []domain.AccountInput{liker})
+	assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+	assert.Zero(t, s.capacity, "the columns mustn't grow for a rejected like")
+
+	err = s.LoadAccounts(context.Background(), []domain.AccountInput{testAccount(101)})
+	assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+	assert.Zero(t, s.capacity)
+}
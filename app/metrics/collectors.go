@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"accounts/app/repository"
+)
+
+// datasetTimeout bounds the time a scrape waits for the dataset stats.
+const datasetTimeout = 5 * time.Second
+
+// poolCollector exposes the stats of the pgx pool at the time of the scrape.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired     *prometheus.Desc
+	idle         *prometheus.Desc
+	total        *prometheus.Desc
+	max          *prometheus.Desc
+	acquires     *prometheus.Desc
+	emptyAcquire *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// NewPoolCollector collects the connections of the pool and the time spent waiting for them.
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		pool:         pool,
+		acquired:     desc("acquired_conns", "Number of the connections in use."),
+		idle:         desc("idle_conns", "Number of the idle connections."),
+		total:        desc("total_conns", "Number of the open connections."),
+		max:          desc("max_conns", "Maximum number of the connections."),
+		acquires:     desc("acquires_total", "Number of the connections acquired."),
+		emptyAcquire: desc("empty_acquires_total", "Number of the acquires waiting for a connection as none was idle."),
+		waitDuration: desc("acquire_wait_seconds_total", "Time spent acquiring the connections."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.acquires
+	ch <- c.emptyAcquire
+	ch <- c.waitDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
+
+// datasetStater is a storage counting its dataset.
+type datasetStater interface {
+	DatasetStats(ctx context.Context) (repository.DatasetStats, error)
+}
+
+// datasetCollector exposes the size of the dataset of the storage at the time of the scrape.
+type datasetCollector struct {
+	storage datasetStater
+
+	accounts  *prometheus.Desc
+	likes     *prometheus.Desc
+	interests *prometheus.Desc
+}
+
+// NewDatasetCollector collects the number of the accounts, the likes and the interests of the storage.
+// Postgres estimates them by the planner statistics, see repository.Repository.DatasetStats.
+func NewDatasetCollector(storage datasetStater) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "dataset", name), help, nil, nil)
+	}
+
+	return &datasetCollector{
+		storage:   storage,
+		accounts:  desc("accounts", "Number of the accounts, estimated by postgres as of the last analyze."),
+		likes:     desc("likes", "Number of the likes, estimated by postgres as of the last analyze."),
+		interests: desc("interests", "Number of the interests of the accounts, estimated by postgres as of the last analyze."),
+	}
+}
+
+func (c *datasetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.accounts
+	ch <- c.likes
+	ch <- c.interests
+}
+
+func (c *datasetCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), datasetTimeout)
+	defer cancel()
+
+	stats, err := c.storage.DatasetStats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.accounts, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.accounts, prometheus.GaugeValue, float64(stats.Accounts))
+	ch <- prometheus.MustNewConstMetric(c.likes, prometheus.GaugeValue, float64(stats.Likes))
+	ch <- prometheus.MustNewConstMetric(c.interests, prometheus.GaugeValue, float64(stats.Interests))
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "accounts"
+
+// routeUnmatched labels the requests no route matched, their paths would make a series each.
+const routeUnmatched = "unmatched"
+
+// durationBuckets cover the sub-millisecond answers of the memory storage
+// as well as the slow queries of postgres.
+var durationBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+// Metrics is the registry of the server metrics exposed to prometheus.
+// A nil Metrics records nothing.
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	operators *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of the requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of the requests by route, method and status.",
+			Buckets:   durationBuckets,
+		}, []string{"route", "method", "status"}),
+		operators: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "query_operators_total",
+			Help:      "Number of the query params by endpoint, field and operation.",
+		}, []string{"endpoint", "field", "op"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.durations,
+		m.operators,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Register adds the collectors, e.g. of the pool and of the dataset.
+func (m *Metrics) Register(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler serves the metrics in the prometheus text format. A failing collector
+// doesn't fail the scrape, the other metrics are still served.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Middleware counts the requests and observes their durations by the route pattern they matched.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{
+			"route":  route(r),
+			"method": r.Method,
+			"status": strconv.Itoa(status),
+		}
+
+		m.requests.With(labels).Inc()
+		m.durations.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// CountOperator counts a query param of the endpoint, the params without an operation count as eq.
+func (m *Metrics) CountOperator(endpoint, field, op string) {
+	if m == nil {
+		return
+	}
+
+	m.operators.WithLabelValues(endpoint, field, op).Inc()
+}
+
+func route(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return routeUnmatched
+}
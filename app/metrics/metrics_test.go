@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app/repository"
+)
+
+func Test_Middleware(t *testing.T) {
+	m := New()
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/accounts/{id}/recommend/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Get("/accounts/filter/", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, path := range []string{"/accounts/1/recommend/", "/accounts/2/recommend/", "/accounts/filter/", "/unknown/"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("/accounts/{id}/recommend/", "GET", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("/accounts/filter/", "GET", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(routeUnmatched, "GET", "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.durations))
+}
+
+func Test_Middleware_Nil(t *testing.T) {
+	var m *Metrics
+	m.CountOperator("filter", "sex", "eq")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	w := httptest.NewRecorder()
+	m.Middleware(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func Test_CountOperator(t *testing.T) {
+	m := New()
+	m.CountOperator("filter", "sex", "eq")
+	m.CountOperator("filter", "sex", "eq")
+	m.CountOperator("group", "sex", "eq")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.operators.WithLabelValues("filter", "sex", "eq")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.operators.WithLabelValues("group", "sex", "eq")))
+}
+
+type testStater struct {
+	stats repository.DatasetStats
+	err   error
+}
+
+func (s testStater) DatasetStats(ctx context.Context) (repository.DatasetStats, error) {
+	return s.stats, s.err
+}
+
+func Test_DatasetCollector(t *testing.T) {
+	c := NewDatasetCollector(testStater{stats: repository.DatasetStats{Accounts: 3, Likes: 10, Interests: 7}})
+	expected := `
+# HELP accounts_dataset_accounts Number of the accounts, estimated by postgres as of the last analyze.
+# TYPE accounts_dataset_accounts gauge
+accounts_dataset_accounts 3
+# HELP accounts_dataset_interests Number of the interests of the accounts, estimated by postgres as of the last analyze.
+# TYPE accounts_dataset_interests gauge
+accounts_dataset_interests 7
+# HELP accounts_dataset_likes Number of the likes, estimated by postgres as of the last analyze.
+# TYPE accounts_dataset_likes gauge
+accounts_dataset_likes 10
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
+
+// Test_Handler_FailingCollector checks that a failing storage doesn't hide the other metrics.
+func Test_Handler_FailingCollector(t *testing.T) {
+	m := New()
+	require.NoError(t, m.Register(NewDatasetCollector(testStater{err: errors.New("connection refused")})))
+	m.CountOperator("filter", "sex", "eq")
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `accounts_query_operators_total{endpoint="filter",field="sex",op="eq"} 1`)
+	assert.NotContains(t, w.Body.String(), "accounts_dataset_accounts")
+}
+
+func Test_PoolCollector(t *testing.T) {
+	config, err := pgxpool.ParseConfig("postgres://localhost:5432/accounts?pool_max_conns=7")
+	require.NoError(t, err)
+	config.LazyConnect = true
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), config)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	c := NewPoolCollector(pool)
+	assert.Equal(t, 7, testutil.CollectAndCount(c))
+
+	expected := `
+# HELP accounts_db_pool_acquired_conns Number of the connections in use.
+# TYPE accounts_db_pool_acquired_conns gauge
+accounts_db_pool_acquired_conns 0
+# HELP accounts_db_pool_max_conns Maximum number of the connections.
+# TYPE accounts_db_pool_max_conns gauge
+accounts_db_pool_max_conns 7
+`
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"accounts_db_pool_acquired_conns", "accounts_db_pool_max_conns"))
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"accounts/util"
+)
+
+// slowRequest is the duration a request is logged as slow at, the contest expects answers in milliseconds.
+const slowRequest = 100 * time.Millisecond
+
+// requestLogger passes the logger with the id of the request down the context
+// and logs the endpoint, the status and the duration of every request:
+// the failed ones as errors, the ones taking slow or longer as warnings and the rest as debug,
+// so that the load doesn't flood the log. It's expected to run after middleware.RequestID.
+func requestLogger(logger *util.Logger, slow time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := middleware.GetReqID(r.Context())
+			w.Header().Set(middleware.RequestIDHeader, id)
+
+			reqLogger := logger.With("request_id", id)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(util.WithLogger(r.Context(), reqLogger)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			duration := time.Since(start)
+			kv := []interface{}{
+				"method", r.Method,
+				"endpoint", endpoint(r),
+				"status", status,
+				"duration", duration,
+			}
+
+			switch {
+			case status >= http.StatusInternalServerError:
+				reqLogger.Error("request", kv...)
+			case duration >= slow:
+				reqLogger.Warn("slow request", kv...)
+			default:
+				reqLogger.Debug("request", kv...)
+			}
+		})
+	}
+}
+
+// endpoint is the route pattern the request matched, so that the requests of an endpoint
+// share it whatever the ids and the params, or the path if no route matched.
+func endpoint(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+
+	return r.URL.Path
+}
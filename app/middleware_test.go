@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app/controller"
+	"accounts/app/memory"
+	"accounts/app/metrics"
+	"accounts/app/service"
+	"accounts/util"
+)
+
+func Test_requestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := util.NewLogger(&out, util.LevelDebug)
+	router := Router(controller.New(service.New(memory.New())), logger, nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/accounts/1/recommend/?query_id=1&limit=1", nil)
+	r.Header.Set(middleware.RequestIDHeader, "req-1")
+	router.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(middleware.RequestIDHeader))
+	assert.Contains(t, out.String(), "level=debug msg=request request_id=req-1 method=GET endpoint=/accounts/{id}/recommend/ status=404 duration=")
+
+	out.Reset()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accounts/filter/?query_id=1&limit=1", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(middleware.RequestIDHeader))
+	assert.Contains(t, out.String(), "endpoint=/accounts/filter/ status=200")
+}
+
+func Test_requestLogger_Levels(t *testing.T) {
+	var out bytes.Buffer
+	logger := util.NewLogger(&out, util.LevelInfo)
+
+	serve := func(slow time.Duration, status int) {
+		out.Reset()
+		handler := requestLogger(logger, slow)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/accounts/filter/", nil))
+	}
+
+	serve(time.Hour, http.StatusOK)
+	assert.Empty(t, out.String(), "a successful request is logged at debug")
+
+	serve(time.Hour, http.StatusNotFound)
+	assert.Empty(t, out.String())
+
+	serve(0, http.StatusOK)
+	assert.Contains(t, out.String(), "level=warn msg=\"slow request\"")
+	assert.Contains(t, out.String(), "status=200")
+
+	serve(0, http.StatusInternalServerError)
+	assert.Contains(t, out.String(), "level=error msg=request")
+	assert.Contains(t, out.String(), "status=500")
+}
+
+func Test_Router_Metrics(t *testing.T) {
+	m := metrics.New()
+	mem := memory.New()
+	require.NoError(t, m.Register(metrics.NewDatasetCollector(mem)))
+	router := Router(controller.New(service.New(mem).WithOperatorCounter(m)), nil, m)
+
+	accounts := []string{
+		`{"id": 1, "email": "1@mail.ru", "sex": "m", "birth": 631152000, "joined": 1420070400, "status": "свободны",
+			"interests": ["бокс", "кино"]}`,
+		`{"id": 2, "email": "2@mail.ru", "sex": "f", "birth": 631152000, "joined": 1420070400, "status": "свободны",
+			"interests": ["кино"], "likes": [{"id": 1, "ts": 1420070400}]}`,
+	}
+	for _, account := range accounts {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/accounts/new/", strings.NewReader(account)))
+		require.Equal(t, http.StatusCreated, w.Code, account)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accounts/filter/?sex_eq=m&interests_any=кино&limit=5&query_id=1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	for _, line := range []string{
+		`accounts_http_requests_total{method="POST",route="/accounts/new/",status="201"} 2`,
+		`accounts_http_requests_total{method="GET",route="/accounts/filter/",status="200"} 1`,
+		`accounts_http_request_duration_seconds_count{method="GET",route="/accounts/filter/",status="200"} 1`,
+		`accounts_query_operators_total{endpoint="filter",field="sex",op="eq"} 1`,
+		`accounts_query_operators_total{endpoint="filter",field="interests",op="any"} 1`,
+		"accounts_dataset_accounts 2",
+		"accounts_dataset_likes 1",
+		"accounts_dataset_interests 3",
+	} {
+		assert.Contains(t, body, line)
+	}
+}
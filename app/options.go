@@ -0,0 +1,63 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ModeTest   = 0
+	ModeRating = 1
+)
+
+// Options are the run options supplied by the contest in options.txt.
+type Options struct {
+	// Now is the current timestamp premium activity is checked at.
+	Now int64
+	// Mode is ModeTest for the test runs on the small dataset and ModeRating for the rating ones.
+	Mode int
+}
+
+// ReadOptions reads options.txt, the first line of which is the current timestamp
+// and the optional second one is the run mode.
+func ReadOptions(path string) (*Options, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("empty options file: %s", path)
+	}
+
+	now, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &Options{Now: now, Mode: ModeTest}
+	if scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			if options.Mode, err = strconv.Atoi(line); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
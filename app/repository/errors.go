@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+
+	"accounts/domain"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+var (
+	ErrNotFound       = domain.NewNotFoundError(errors.New("not found"))
+	ErrDuplicateEmail = domain.NewConflictError(errors.New("duplicate email"))
+	ErrDuplicatePhone = domain.NewConflictError(errors.New("duplicate phone"))
+	ErrUnknownAccount = domain.NewValidationError(errors.New("unknown account"))
+
+	errNilModel         = errors.New("nil model (input model wasn't validated probably)")
+	errInvalidField     = errors.New("invalid field")
+	errInvalidOperation = errors.New("invalid operation")
+	errInvalidValue     = errors.New("invalid value")
+	errForeignKeys      = errors.New("the foreign keys of the likes are already applied, the snapshot is loaded " +
+		"only into a schema migrated without the deferred constraints, e.g. an empty database with -migrate")
+)
+
+// wrapError assigns a kind to the error returned by the repository:
+// unique violations are conflicts, foreign key violations are invalid input, the rest is internal.
+// It's deferred by the exported methods.
+func wrapError(err *error) {
+	if *err == nil {
+		return
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(*err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			*err = domain.NewConflictError(*err)
+			return
+		case pgForeignKeyViolation:
+			*err = domain.NewValidationError(*err)
+			return
+		}
+	}
+
+	*err = domain.NewInternalError(*err)
+}
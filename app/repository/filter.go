@@ -3,19 +3,59 @@ package repository
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 )
 
+// Operations of the predicates.
+const (
+	OpEq       = "eq"
+	OpNeq      = "neq"
+	OpLike     = "like"
+	OpLt       = "lt"
+	OpGt       = "gt"
+	OpAny      = "any"
+	OpContains = "contains"
+	OpNull     = "null"
+	OpStarts   = "starts"
+	OpDomain   = "domain"
+	OpCode     = "code"
+	OpNow      = "now"
+	OpYear     = "year"
+)
+
+// collateC compares the strings byte by byte whatever the collation of the database,
+// as the memory storage does, so that both storages order and compare them alike.
+const collateC = `COLLATE "C"`
+
+// Predicate is a condition of the filter in a structured form,
+// so that storages not speaking SQL can evaluate the filter too.
+// Values are the arguments the filter method was called with.
+type Predicate struct {
+	Column string
+	Op     string
+	Values []interface{}
+}
+
+// Step is the predicates of a query param with the estimated selectivity of the param,
+// the share of the accounts expected to match it.
+type Step struct {
+	Param       string
+	Selectivity float64
+	Predicates  []Predicate
+}
+
+// Filter is a plan of the filter: the steps in the order they should be evaluated in,
+// the most selective first. SQL is generated from the predicates on demand.
 type Filter struct {
 	Limit int
 	cols  map[string]struct{}
-	ops   []squirrel.Sqlizer
+	steps []Step
 }
 
 func NewFilter() *Filter {
 	return &Filter{
-		ops:  []squirrel.Sqlizer{},
 		cols: make(map[string]struct{}),
 	}
 }
@@ -24,11 +64,56 @@ func (f *Filter) Columns() map[string]struct{} {
 	return f.cols
 }
 
+// Step starts the step the following predicates are added to,
+// the predicates added without a step make one of an unknown selectivity.
+func (f *Filter) Step(param string, selectivity float64) {
+	f.steps = append(f.steps, Step{Param: param, Selectivity: selectivity})
+}
+
+func (f *Filter) Steps() []Step {
+	return f.steps
+}
+
+func (f *Filter) Predicates() []Predicate {
+	predicates := make([]Predicate, 0, len(f.steps))
+	for _, step := range f.steps {
+		predicates = append(predicates, step.Predicates...)
+	}
+
+	return predicates
+}
+
+func (f *Filter) predicate(column, op string, values ...interface{}) {
+	if len(f.steps) == 0 {
+		f.Step("", 1)
+	}
+
+	step := &f.steps[len(f.steps)-1]
+	step.Predicates = append(step.Predicates, Predicate{Column: column, Op: op, Values: values})
+}
+
+// Build returns the filter predicates with dollar placeholders.
 func (f *Filter) Build() (string, []interface{}, error) {
-	predicates := make([]string, 0, len(f.ops))
+	sql, values, err := f.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	sql, err = squirrel.Dollar.ReplacePlaceholders(sql)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sql, values, nil
+}
+
+// ToSql returns the filter predicates with question placeholders,
+// so the filter can be embedded into a query with its own arguments.
+func (f *Filter) ToSql() (string, []interface{}, error) {
+	predicates := make([]string, 0)
 	totalValues := make([]interface{}, 0)
-	for _, op := range f.ops {
-		sql, values, err := op.ToSql()
+	for _, p := range f.Predicates() {
+		sql, values, err := p.ToSql()
 		if err != nil {
 			return "", nil, err
 		}
@@ -37,101 +122,223 @@ func (f *Filter) Build() (string, []interface{}, error) {
 		totalValues = append(totalValues, values...)
 	}
 
-	sql, err := squirrel.Dollar.ReplacePlaceholders(strings.Join(predicates, " AND "))
-	if err != nil {
-		return "", nil, err
-	}
-
-	return sql, totalValues, nil
+	return strings.Join(predicates, " AND "), totalValues, nil
 }
 
 func (f *Filter) Eq(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Eq{column: value})
+	f.predicate(column, OpEq, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Neq(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.NotEq{column: value})
+	f.predicate(column, OpNeq, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Like(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Like{column: value})
+	f.predicate(column, OpLike, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Lt(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Lt{column: value})
+	f.predicate(column, OpLt, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Gt(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Gt{column: value})
+	f.predicate(column, OpGt, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Any(column string, values []interface{}) {
-	f.ops = append(f.ops, squirrel.Eq{column: values})
-	f.cols[column] = struct{}{}
+	f.predicate(column, OpAny, values...)
+	if _, ok := relations[column]; !ok {
+		f.cols[column] = struct{}{}
+	}
 }
 
-// TODO: fix contains operation
+// Contains matches accounts related to every value, e.g. having all the interests listed.
 func (f *Filter) Contains(column string, values []interface{}) {
-	f.ops = append(f.ops, squirrel.Eq{column: values})
-	f.cols[column] = struct{}{}
+	if _, ok := relations[column]; !ok {
+		f.Any(column, values)
+		return
+	}
+
+	f.predicate(column, OpContains, values...)
 }
 
 func (f *Filter) Null(column string, isNull bool) {
-	var op squirrel.Sqlizer
-	if isNull {
-		op = squirrel.Eq{column: nil}
-	} else {
-		op = squirrel.NotEq{column: nil}
-	}
-
-	f.ops = append(f.ops, op)
+	f.predicate(column, OpNull, isNull)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Starts(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Like{column: fmt.Sprintf("%v%%", value)})
+	f.predicate(column, OpStarts, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Domain(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Like{column: fmt.Sprintf("%%@%v", value)})
+	f.predicate(column, OpDomain, value)
 	f.cols[column] = struct{}{}
 }
 
 func (f *Filter) Code(column string, value interface{}) {
-	f.ops = append(f.ops, squirrel.Like{column: fmt.Sprintf("%%(%v)%%", value)})
+	f.predicate(column, OpCode, value)
 	f.cols[column] = struct{}{}
 }
 
-func (f *Filter) Now(value interface{}) {
-	f.ops = append(f.ops, squirrel.And{
-		squirrel.LtOrEq{AccountPremStart: value},
-		squirrel.GtOrEq{AccountPremEnd: value},
-	})
+// Now matches accounts which premium is active (or not active) at the moment.
+func (f *Filter) Now(now time.Time, active bool) {
+	f.predicate(AccountPremStart, OpNow, now, active)
+	f.cols[AccountPremStart] = struct{}{}
 }
 
-func (f *Filter) Year(column string, value interface{}) {
-	// TODO: add operation
+func (f *Filter) Year(column string, year int) {
+	f.predicate(column, OpYear, year)
 	f.cols[column] = struct{}{}
 }
 
-// type opAll struct {
-// 	Field  string
-// 	SubQ   string
-// 	Values []interface{}
-// }
+func (p Predicate) ToSql() (string, []interface{}, error) {
+	return p.sqlizer().ToSql()
+}
+
+func (p Predicate) sqlizer() squirrel.Sqlizer {
+	switch p.Op {
+	case OpEq:
+		return squirrel.Eq{p.Column: p.Values[0]}
+	case OpNeq:
+		return squirrel.NotEq{p.Column: p.Values[0]}
+	case OpLike:
+		return squirrel.Like{p.Column: p.Values[0]}
+	case OpLt:
+		if p.Column == AccountEmail {
+			return squirrel.Expr(fmt.Sprintf("%s %s < ?", p.Column, collateC), p.Values[0])
+		}
+
+		return squirrel.Lt{p.Column: p.Values[0]}
+	case OpGt:
+		if p.Column == AccountEmail {
+			return squirrel.Expr(fmt.Sprintf("%s %s > ?", p.Column, collateC), p.Values[0])
+		}
+
+		return squirrel.Gt{p.Column: p.Values[0]}
+	case OpAny:
+		if rel, ok := relations[p.Column]; ok {
+			return &opRelated{relation: rel, Field: p.Column, Values: p.Values}
+		}
+
+		return squirrel.Eq{p.Column: p.Values}
+	case OpContains:
+		return &opRelated{relation: relations[p.Column], Field: p.Column, Values: p.Values, All: true}
+	case OpNull:
+		if p.Values[0].(bool) {
+			return squirrel.Eq{p.Column: nil}
+		}
+
+		return squirrel.NotEq{p.Column: nil}
+	case OpStarts:
+		return squirrel.Like{p.Column: fmt.Sprintf("%v%%", p.Values[0])}
+	case OpDomain:
+		// the expressions match the ones of the indexes in migrations/0003_indexes.up.sql
+		return squirrel.Expr(fmt.Sprintf("split_part(%s, '@', 2) = ?", p.Column), fmt.Sprint(p.Values[0]))
+	case OpCode:
+		return squirrel.Expr(fmt.Sprintf(`substring(%s from '\((\d+)\)') = ?`, p.Column), fmt.Sprint(p.Values[0]))
+	case OpNow:
+		now := p.Values[0]
+		if p.Values[1].(bool) {
+			return squirrel.And{
+				squirrel.LtOrEq{AccountPremStart: now},
+				squirrel.GtOrEq{AccountPremEnd: now},
+			}
+		}
+
+		return squirrel.Or{
+			squirrel.Eq{AccountPremStart: nil},
+			squirrel.Gt{AccountPremStart: now},
+			squirrel.Lt{AccountPremEnd: now},
+		}
+	case OpYear:
+		// the column is compared with the bounds of the year instead of extracting the year
+		// from every row, so that an index on the column can be used
+		year, ok := p.Values[0].(int)
+		if !ok {
+			return invalidValue(p)
+		}
+
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return squirrel.And{
+			squirrel.GtOrEq{p.Column: from},
+			squirrel.Lt{p.Column: from.AddDate(1, 0, 0)},
+		}
+	}
+
+	return invalidPredicate(p)
+}
+
+// invalidPredicate fails building the query with an unknown operation.
+type invalidPredicate Predicate
+
+func (p invalidPredicate) ToSql() (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("%w: %s %s", errInvalidOperation, p.Column, p.Op)
+}
+
+// invalidValue fails building the query with a value of an unexpected type for the operation.
+type invalidValue Predicate
 
-// func (op *opAll) ToSql() (string, []interface{}, error) {
-// 	var b strings.Builder
-// 	b.WriteString(op.Field)
-// 	b.WriteString(" = IN(")
-// 	b.WriteString(op.SubQ)
-// 	b.WriteRune(')')
+func (p invalidValue) ToSql() (string, []interface{}, error) {
+	return "", nil, fmt.Errorf("%w: %s %s %v", errInvalidValue, p.Column, p.Op, p.Values)
+}
+
+// relation describes a table keeping multiple values per account
+type relation struct {
+	table string
+	owner string
+}
 
-// 	return b.String(), op.Values, nil
-// }
+var relations = map[string]relation{
+	InterestName: {table: TableInterest, owner: InterestAccountID},
+	LikesLikeeID: {table: TableLike, owner: LikesLikerID},
+}
+
+// opRelated selects accounts by a subquery on the related table instead of joining it,
+// so an account is never duplicated in the result.
+type opRelated struct {
+	relation
+	Field  string
+	Values []interface{}
+	All    bool
+}
+
+func (op *opRelated) ToSql() (string, []interface{}, error) {
+	values := distinct(op.Values)
+	q := squirrel.Select(op.owner).
+		From(op.table).
+		Where(squirrel.Eq{op.Field: values})
+
+	if op.All {
+		q = q.GroupBy(op.owner).
+			Having(fmt.Sprintf("COUNT(DISTINCT %s) = ?", op.Field), len(values))
+	}
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fmt.Sprintf("%s IN (%s)", AccountID, sql), args, nil
+}
+
+func distinct(values []interface{}) []interface{} {
+	seen := make(map[interface{}]struct{}, len(values))
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+
+	return result
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"accounts/domain"
+)
+
+// outputField is a field of domain.AccountOut which is output when its column is filtered by.
+type outputField struct {
+	column string
+	// selects are the expressions selected for the field, timestamps are selected as unix seconds
+	selects []string
+	// targets returns the scan destinations of the selects and
+	// a func putting the scanned values into the account (if they can't be scanned into it directly)
+	targets func(a *domain.AccountOut) ([]interface{}, func())
+}
+
+// outputFields are listed in the order they are selected.
+var outputFields = []outputField{
+	{
+		column:  AccountID,
+		selects: []string{AccountID},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.ID}, nil
+		},
+	},
+	{
+		column:  AccountEmail,
+		selects: []string{AccountEmail},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Email}, nil
+		},
+	},
+	{
+		column:  AccountSex,
+		selects: []string{AccountSex},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Sex}, nil
+		},
+	},
+	{
+		column:  AccountStatus,
+		selects: []string{AccountStatus},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Status}, nil
+		},
+	},
+	{
+		column:  AccountFirstname,
+		selects: []string{AccountFirstname},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Fname}, nil
+		},
+	},
+	{
+		column:  AccountSurname,
+		selects: []string{AccountSurname},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Sname}, nil
+		},
+	},
+	{
+		column:  AccountPhone,
+		selects: []string{AccountPhone},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Phone}, nil
+		},
+	},
+	{
+		column:  AccountBirth,
+		selects: []string{epoch(AccountBirth)},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Birth}, nil
+		},
+	},
+	{
+		column:  CountryName,
+		selects: []string{CountryName},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.Country}, nil
+		},
+	},
+	{
+		column:  CityName,
+		selects: []string{CityName},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			return []interface{}{&a.City}, nil
+		},
+	},
+	{
+		column:  AccountPremStart,
+		selects: []string{epoch(AccountPremStart), epoch(AccountPremEnd)},
+		targets: func(a *domain.AccountOut) ([]interface{}, func()) {
+			var start, finish *int64
+			return []interface{}{&start, &finish}, func() {
+				a.Premium = premiumOut(start, finish)
+			}
+		},
+	},
+}
+
+type row interface {
+	Scan(dest ...interface{}) error
+}
+
+// projection selects id, email and the output fields of the given columns.
+// Columns without output fields (e.g. interests or likes) are never output.
+type projection struct {
+	fields []outputField
+}
+
+func newProjection(columns map[string]struct{}) *projection {
+	fields := make([]outputField, 0, len(outputFields))
+	for _, field := range outputFields {
+		if field.column == AccountID || field.column == AccountEmail {
+			fields = append(fields, field)
+			continue
+		}
+
+		if _, ok := columns[field.column]; ok {
+			fields = append(fields, field)
+		}
+	}
+
+	return &projection{fields: fields}
+}
+
+func projectionOf(columns ...string) *projection {
+	set := make(map[string]struct{}, len(columns))
+	for _, column := range columns {
+		set[column] = struct{}{}
+	}
+
+	return newProjection(set)
+}
+
+func (p *projection) Columns() []string {
+	columns := make([]string, 0, len(p.fields))
+	for _, field := range p.fields {
+		columns = append(columns, field.selects...)
+	}
+
+	return columns
+}
+
+func (p *projection) Scan(r row) (domain.AccountOut, error) {
+	var a domain.AccountOut
+	dest := make([]interface{}, 0, len(p.fields))
+	apply := make([]func(), 0)
+	for _, field := range p.fields {
+		targets, fn := field.targets(&a)
+		dest = append(dest, targets...)
+		if fn != nil {
+			apply = append(apply, fn)
+		}
+	}
+
+	if err := r.Scan(dest...); err != nil {
+		return a, err
+	}
+
+	for _, fn := range apply {
+		fn()
+	}
+
+	return a, nil
+}
+
+func premiumOut(start, finish *int64) *domain.PremiumOut {
+	if start == nil || finish == nil {
+		return nil
+	}
+
+	return &domain.PremiumOut{
+		Start:  *start,
+		Finish: *finish,
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRow scans values into the destinations like pgx does for the types used by projections.
+type fakeRow []interface{}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("expected %d destinations, got %d", len(r), len(dest))
+	}
+
+	for i, value := range r {
+		switch d := dest[i].(type) {
+		case *int32:
+			*d = value.(int32)
+		case *int64:
+			*d = value.(int64)
+		case *string:
+			*d = value.(string)
+		case **string:
+			if value != nil {
+				v := value.(string)
+				*d = &v
+			}
+		case **int64:
+			if value != nil {
+				v := value.(int64)
+				*d = &v
+			}
+		default:
+			return fmt.Errorf("unexpected destination %T", d)
+		}
+	}
+
+	return nil
+}
+
+type testCaseProjection struct {
+	Column   string
+	Columns  []string
+	Row      fakeRow
+	Expected string
+}
+
+func Test_projection_Scan(t *testing.T) {
+	testcases := []testCaseProjection{
+		{
+			Column:   AccountSex,
+			Columns:  []string{AccountID, AccountEmail, AccountSex},
+			Row:      fakeRow{int32(1), "a@b.ru", "m"},
+			Expected: `{"id":1,"email":"a@b.ru","sex":"m"}`,
+		},
+		{
+			Column:   AccountStatus,
+			Columns:  []string{AccountID, AccountEmail, AccountStatus},
+			Row:      fakeRow{int32(1), "a@b.ru", "заняты"},
+			Expected: `{"id":1,"email":"a@b.ru","status":"заняты"}`,
+		},
+		{
+			Column:   AccountFirstname,
+			Columns:  []string{AccountID, AccountEmail, AccountFirstname},
+			Row:      fakeRow{int32(1), "a@b.ru", "Иван"},
+			Expected: `{"id":1,"email":"a@b.ru","fname":"Иван"}`,
+		},
+		{
+			Column:   AccountFirstname,
+			Columns:  []string{AccountID, AccountEmail, AccountFirstname},
+			Row:      fakeRow{int32(1), "a@b.ru", nil},
+			Expected: `{"id":1,"email":"a@b.ru"}`,
+		},
+		{
+			Column:   AccountSurname,
+			Columns:  []string{AccountID, AccountEmail, AccountSurname},
+			Row:      fakeRow{int32(1), "a@b.ru", "Иванов"},
+			Expected: `{"id":1,"email":"a@b.ru","sname":"Иванов"}`,
+		},
+		{
+			Column:   AccountPhone,
+			Columns:  []string{AccountID, AccountEmail, AccountPhone},
+			Row:      fakeRow{int32(1), "a@b.ru", "8(999)1234567"},
+			Expected: `{"id":1,"email":"a@b.ru","phone":"8(999)1234567"}`,
+		},
+		{
+			Column:   AccountBirth,
+			Columns:  []string{AccountID, AccountEmail, epoch(AccountBirth)},
+			Row:      fakeRow{int32(1), "a@b.ru", int64(631152000)},
+			Expected: `{"id":1,"email":"a@b.ru","birth":631152000}`,
+		},
+		{
+			Column:   CountryName,
+			Columns:  []string{AccountID, AccountEmail, CountryName},
+			Row:      fakeRow{int32(1), "a@b.ru", "Россия"},
+			Expected: `{"id":1,"email":"a@b.ru","country":"Россия"}`,
+		},
+		{
+			Column:   CityName,
+			Columns:  []string{AccountID, AccountEmail, CityName},
+			Row:      fakeRow{int32(1), "a@b.ru", nil},
+			Expected: `{"id":1,"email":"a@b.ru"}`,
+		},
+		{
+			Column:   AccountPremStart,
+			Columns:  []string{AccountID, AccountEmail, epoch(AccountPremStart), epoch(AccountPremEnd)},
+			Row:      fakeRow{int32(1), "a@b.ru", int64(1514764800), int64(1517443200)},
+			Expected: `{"id":1,"email":"a@b.ru","premium":{"start":1514764800,"finish":1517443200}}`,
+		},
+		{
+			Column:   AccountPremStart,
+			Columns:  []string{AccountID, AccountEmail, epoch(AccountPremStart), epoch(AccountPremEnd)},
+			Row:      fakeRow{int32(1), "a@b.ru", nil, nil},
+			Expected: `{"id":1,"email":"a@b.ru"}`,
+		},
+		{
+			Column:   InterestName,
+			Columns:  []string{AccountID, AccountEmail},
+			Row:      fakeRow{int32(1), "a@b.ru"},
+			Expected: `{"id":1,"email":"a@b.ru"}`,
+		},
+		{
+			Column:   AccountJoined,
+			Columns:  []string{AccountID, AccountEmail},
+			Row:      fakeRow{int32(1), "a@b.ru"},
+			Expected: `{"id":1,"email":"a@b.ru"}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		p := projectionOf(tc.Column)
+		assert.Equal(t, tc.Columns, p.Columns(), tc.Column)
+
+		acc, err := p.Scan(tc.Row)
+		require.NoError(t, err, tc.Column)
+
+		body, err := jsoniter.Marshal(acc)
+		require.NoError(t, err, tc.Column)
+		assert.JSONEq(t, tc.Expected, string(body), tc.Column)
+	}
+}
+
+func Test_projection_ColumnsOrder(t *testing.T) {
+	p := projectionOf(CityName, AccountPremStart, AccountSex, LikesLikeeID, AccountBirth)
+
+	expected := []string{
+		AccountID, AccountEmail, AccountSex, epoch(AccountBirth), CityName,
+		epoch(AccountPremStart), epoch(AccountPremEnd),
+	}
+
+	assert.Equal(t, expected, p.Columns())
+}
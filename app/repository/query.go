@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -42,33 +43,60 @@ const (
 	CountryName       = "country.name"
 )
 
-func buildAccountSearchQuery(f *Filter) (string, []interface{}, error) {
-	where, params, err := f.Build()
+func buildAccountSearchQuery(f *Filter, p *projection) (string, []interface{}, error) {
+	where, params, err := f.ToSql()
 	if err != nil {
 		return "", nil, err
 	}
 
-	q := squirrel.Select(AccountID, AccountEmail).
+	q := squirrel.Select(p.Columns()...).
 		PlaceholderFormat(squirrel.Dollar).
 		From(TableAccount).
 		Where(where, params...).
 		OrderBy(AccountID + " DESC")
 
+	q = joinTables(q, f.Columns())
+
+	if f.Limit != 0 {
+		q = q.Limit(uint64(f.Limit))
+	}
+
+	return q.ToSql()
+}
+
+func buildAccountGroupQuery(f *Filter, keys []string, asc bool) (string, []interface{}, error) {
+	where, params, err := f.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	direction := " DESC"
+	if asc {
+		direction = " ASC"
+	}
+
+	orderBy := make([]string, 0, len(keys)+1)
+	orderBy = append(orderBy, "count"+direction)
+	columns := make(map[string]struct{}, len(keys)+len(f.Columns()))
+	for _, key := range keys {
+		orderBy = append(orderBy, key+" "+collateC+direction)
+		columns[key] = struct{}{}
+	}
+
 	for column := range f.Columns() {
-		switch column {
-		case AccountSex, AccountStatus, AccountBirth, AccountPhone, AccountFirstname, AccountSurname:
-			q = q.Column(column)
-		case CityName:
-			q = q.Column(column).Join(join(TableCity, CityID, AccountCityID))
-		case CountryName:
-			q = q.Column(column).Join(join(TableCountry, CountryID, AccountCountryID))
-		case LikesLikerID:
-			q = q.Join(join(TableLike, LikesLikerID, AccountID))
-		case InterestName:
-			q = q.Join(join(TableInterest, InterestAccountID, AccountID))
-		}
+		columns[column] = struct{}{}
 	}
 
+	q := squirrel.Select(keys...).
+		Column("COUNT(*) AS count").
+		PlaceholderFormat(squirrel.Dollar).
+		From(TableAccount).
+		Where(where, params...).
+		GroupBy(keys...).
+		OrderBy(orderBy...)
+
+	q = joinTables(q, columns)
+
 	if f.Limit != 0 {
 		q = q.Limit(uint64(f.Limit))
 	}
@@ -76,6 +104,130 @@ func buildAccountSearchQuery(f *Filter) (string, []interface{}, error) {
 	return q.ToSql()
 }
 
+var (
+	recommendProjection = projectionOf(AccountStatus, AccountFirstname, AccountSurname, AccountBirth, AccountPremStart)
+	suggestProjection   = projectionOf(AccountStatus, AccountFirstname, AccountSurname)
+)
+
+// buildRecommendQuery selects accounts of the opposite sex having at least one common interest with the target.
+// Accounts with an active premium go first, then by status, number of common interests and age difference.
+func buildRecommendQuery(f *Filter, target domain.AccountModel, now time.Time) (string, []interface{}, error) {
+	where, params, err := f.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the interests aren't unique per account, a duplicate counts once as in the memory storage
+	common, commonValues, err := squirrel.Select(InterestAccountID, fmt.Sprintf("COUNT(DISTINCT %s) AS common", InterestName)).
+		From(TableInterest).
+		Where(fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ?)",
+			InterestName, shortName(InterestName), TableInterest, shortName(InterestAccountID)), target.ID).
+		GroupBy(InterestAccountID).
+		ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	q := squirrel.Select(recommendProjection.Columns()...).
+		PlaceholderFormat(squirrel.Dollar).
+		From(TableAccount).
+		Join(fmt.Sprintf("(%s) AS common ON common.account_id = %s", common, AccountID), commonValues...).
+		Where(squirrel.NotEq{AccountSex: target.Sex}).
+		Where(where, params...).
+		OrderByClause(fmt.Sprintf("COALESCE(%s <= ? AND %s >= ?, false) DESC", AccountPremStart, AccountPremEnd), now, now).
+		OrderByClause(fmt.Sprintf("CASE %s WHEN ? THEN 0 WHEN ? THEN 1 ELSE 2 END", AccountStatus),
+			domain.StatusFree, domain.StatusComplicated).
+		OrderBy("common.common DESC").
+		OrderByClause(fmt.Sprintf("ABS(EXTRACT(EPOCH FROM %s - ?))", AccountBirth), target.Birth).
+		OrderBy(AccountID)
+
+	q = joinTables(q, f.Columns())
+
+	if f.Limit != 0 {
+		q = q.Limit(uint64(f.Limit))
+	}
+
+	return q.ToSql()
+}
+
+// buildSuggestQuery selects accounts liked by the accounts of the same sex similar to the target,
+// excluding the ones the target has already liked. The similarity of two accounts is the sum
+// of 1/|ts1 - ts2| over the accounts liked by both of them, repeated likes are averaged.
+// Each suggested account is ranked by the most similar account that liked it.
+func buildSuggestQuery(f *Filter, target domain.AccountModel) (string, []interface{}, error) {
+	where, params, err := f.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	liked := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", shortName(LikesLikeeID), TableLike, shortName(LikesLikerID))
+
+	targetLikes := squirrel.Select(LikesLikeeID, avgEpoch(LikesTimestamp)+" AS ts").
+		From(TableLike).
+		Where(squirrel.Eq{LikesLikerID: target.ID}).
+		GroupBy(LikesLikeeID)
+
+	likerLikes, likerValues, err := squirrel.Select(LikesLikerID, LikesLikeeID, avgEpoch(LikesTimestamp)+" AS ts").
+		From(TableLike).
+		Where(fmt.Sprintf("%s IN (%s)", LikesLikeeID, liked), target.ID).
+		GroupBy(LikesLikerID, LikesLikeeID).
+		ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	similar := squirrel.Select("liker.liker_id", "SUM(1 / GREATEST(ABS(liker.ts - target.ts), 1)) AS similarity").
+		FromSelect(targetLikes, "target").
+		Join(fmt.Sprintf("(%s) AS liker ON liker.likee_id = target.likee_id", likerLikes), likerValues...).
+		Join(join(TableAccount, AccountID, "liker.liker_id")).
+		Where(squirrel.NotEq{"liker.liker_id": target.ID}).
+		Where(squirrel.Eq{AccountSex: target.Sex}).
+		Where(where, params...).
+		GroupBy("liker.liker_id")
+
+	similar = joinTables(similar, f.Columns())
+
+	columns := suggestProjection.Columns()
+	q := squirrel.Select(columns...).
+		PlaceholderFormat(squirrel.Dollar).
+		FromSelect(similar, "similar").
+		Join(join(TableLike, LikesLikerID, "similar.liker_id")).
+		Join(join(TableAccount, AccountID, LikesLikeeID)).
+		Where(fmt.Sprintf("%s NOT IN (%s)", LikesLikeeID, liked), target.ID).
+		GroupBy(columns...).
+		OrderBy("MAX(similar.similarity) DESC", AccountID+" DESC")
+
+	if f.Limit != 0 {
+		q = q.Limit(uint64(f.Limit))
+	}
+
+	return q.ToSql()
+}
+
+func buildAccountSelectQuery(id int32) (string, []interface{}, error) {
+	return squirrel.Select(AccountID, AccountSex, AccountBirth).
+		PlaceholderFormat(squirrel.Dollar).
+		From(TableAccount).
+		Where(squirrel.Eq{AccountID: id}).
+		ToSql()
+}
+
+// joinTables joins every table the columns belong to exactly once.
+// City and country are joined with LEFT JOIN so that accounts without them are kept.
+func joinTables(q squirrel.SelectBuilder, columns map[string]struct{}) squirrel.SelectBuilder {
+	if _, ok := columns[CityName]; ok {
+		q = q.LeftJoin(join(TableCity, CityID, AccountCityID))
+	}
+	if _, ok := columns[CountryName]; ok {
+		q = q.LeftJoin(join(TableCountry, CountryID, AccountCountryID))
+	}
+	if _, ok := columns[InterestName]; ok {
+		q = q.Join(join(TableInterest, InterestAccountID, AccountID))
+	}
+
+	return q
+}
+
 func buildAccountUpdateQuery(a domain.AccountUpdate, cityID, countryID uuid.UUID) (string, []interface{}, error) {
 	setMap := make(map[string]interface{})
 	if cityID != uuid.Nil {
@@ -88,11 +240,36 @@ func buildAccountUpdateQuery(a domain.AccountUpdate, cityID, countryID uuid.UUID
 	if a.Email != nil {
 		setMap[shortName(AccountEmail)] = a.Email
 	}
+	if a.Sex != nil {
+		setMap[shortName(AccountSex)] = a.Sex
+	}
 	if a.Birth != nil {
 		setMap[shortName(AccountBirth)] = util.TimestampToDatetime((*int64)(a.Birth))
 	}
+	if a.Joined != nil {
+		setMap[shortName(AccountJoined)] = util.TimestampToDatetime((*int64)(a.Joined))
+	}
 	if a.Status != nil {
-		setMap[shortName(AccountStatus)] = util.TimestampToDatetime((*int64)(a.Birth))
+		setMap[shortName(AccountStatus)] = a.Status
+	}
+	if a.Name != nil {
+		setMap[shortName(AccountFirstname)] = a.Name
+	}
+	if a.Surname != nil {
+		setMap[shortName(AccountSurname)] = a.Surname
+	}
+	if a.Phone != nil {
+		setMap[shortName(AccountPhone)] = a.Phone
+	}
+	if a.Premium != nil {
+		setMap[shortName(AccountPremStart)] = util.TimestampToDatetime((*int64)(a.Premium.Start))
+		setMap[shortName(AccountPremEnd)] = util.TimestampToDatetime((*int64)(a.Premium.End))
+	}
+
+	// the row is still updated (and locked) when only interests or likes change,
+	// so that an unknown account is detected by the number of affected rows
+	if len(setMap) == 0 {
+		setMap[shortName(AccountID)] = squirrel.Expr(shortName(AccountID))
 	}
 
 	return squirrel.Update(TableAccount).
@@ -102,6 +279,49 @@ func buildAccountUpdateQuery(a domain.AccountUpdate, cityID, countryID uuid.UUID
 		ToSql()
 }
 
+// buildDuplicateQuery selects the email and phone of another account having the same email or phone.
+func buildDuplicateQuery(id int32, email, phone *string) (string, []interface{}, error) {
+	or := squirrel.Or{}
+	if email != nil {
+		or = append(or, squirrel.Eq{AccountEmail: *email})
+	}
+	if phone != nil {
+		or = append(or, squirrel.Eq{AccountPhone: *phone})
+	}
+
+	return squirrel.Select(AccountEmail, AccountPhone).
+		From(TableAccount).
+		Where(or).
+		Where(squirrel.NotEq{AccountID: id}).
+		Limit(1).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func buildAccountsCountQuery(ids []int32) (string, []interface{}, error) {
+	return squirrel.Select("COUNT(*)").
+		From(TableAccount).
+		Where(squirrel.Eq{AccountID: ids}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+// buildNamesSelectQuery selects the ids and the names of the cities or the countries having the names.
+func buildNamesSelectQuery(table, idColumn, nameColumn string, names []string) (string, []interface{}, error) {
+	return squirrel.Select(idColumn, nameColumn).
+		From(table).
+		Where(squirrel.Eq{nameColumn: names}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
+func buildInterestsDeleteQuery(accountID int32) (string, []interface{}, error) {
+	return squirrel.Delete(TableInterest).
+		Where(squirrel.Eq{InterestAccountID: accountID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+}
+
 func buildAccountInsertQuery(a domain.AccountModel) (string, []interface{}, error) {
 	return squirrel.Insert(TableAccount).
 		Columns(shortName(AccountID), shortName(AccountStatus), shortName(AccountEmail),
@@ -151,6 +371,14 @@ func buildCountryInsertQuery(c domain.CountryModel) (sql string, values []interf
 	)
 }
 
+func epoch(column string) string {
+	return fmt.Sprintf("EXTRACT(EPOCH FROM %s)::bigint", column)
+}
+
+func avgEpoch(column string) string {
+	return fmt.Sprintf("AVG(EXTRACT(EPOCH FROM %s))", column)
+}
+
 func join(table, left, right string) string {
 	return fmt.Sprintf("%s ON %s = %s", table, left, right)
 }
@@ -201,3 +429,31 @@ func cte(name string, q squirrel.Sqlizer) (sql string, values []interface{}, err
 	sql = fmt.Sprintf("WITH %s AS (%s)", name, sql)
 	return
 }
+
+// buildDatasetStatsQuery selects the planner estimates of the rows of the tables visible on the search path.
+func buildDatasetStatsQuery() (string, []interface{}, error) {
+	return squirrel.Select("relname", "greatest(reltuples, 0)::bigint").
+		PlaceholderFormat(squirrel.Dollar).
+		From("pg_class").
+		Where(squirrel.Eq{
+			"relkind": "r",
+			"relname": []string{TableAccount, TableLike, TableInterest},
+		}).
+		Where("pg_table_is_visible(oid)").
+		ToSql()
+}
+
+// buildHasAccountsQuery checks whether the account table has any row.
+func buildHasAccountsQuery() (string, []interface{}, error) {
+	return squirrel.Select(fmt.Sprintf("EXISTS (SELECT 1 FROM %s)", TableAccount)).ToSql()
+}
+
+// buildForeignKeysQuery counts the foreign keys of the table visible on the search path.
+func buildForeignKeysQuery(table string) (string, []interface{}, error) {
+	return squirrel.Select("COUNT(*)").
+		PlaceholderFormat(squirrel.Dollar).
+		From("pg_constraint").
+		Where(squirrel.Eq{"contype": "f"}).
+		Where("conrelid = to_regclass(?)", table).
+		ToSql()
+}
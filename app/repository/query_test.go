@@ -12,7 +12,6 @@ import (
 )
 
 func Test_buildAccountSearchQuery_Success(t *testing.T) {
-	t.Skip()
 	f := NewFilter()
 	f.Eq(AccountSex, "m")
 	f.Domain(AccountEmail, "test.ru")
@@ -20,14 +19,14 @@ func Test_buildAccountSearchQuery_Success(t *testing.T) {
 	f.Null(CountryName, false)
 	f.Limit = 10
 
-	sql, values, err := buildAccountSearchQuery(f)
+	sql, values, err := buildAccountSearchQuery(f, newProjection(f.Columns()))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	expected := "SELECT account.id, account.email, account.sex, account.name, country.name FROM account "
-	expected += "JOIN country ON country.id = account.country_id "
-	expected += "WHERE account.sex = $1 AND account.email LIKE $2 "
+	expected += "LEFT JOIN country ON country.id = account.country_id "
+	expected += "WHERE account.sex = $1 AND split_part(account.email, '@', 2) = $2 "
 	expected += "AND account.name IN ($3,$4) AND country.name IS NOT NULL "
 	expected += "ORDER BY account.id DESC "
 	expected += "LIMIT 10"
@@ -36,6 +35,19 @@ func Test_buildAccountSearchQuery_Success(t *testing.T) {
 	assert.Equal(t, 4, len(values))
 }
 
+func Test_Filter_CodeMatchesIndexExpression(t *testing.T) {
+	f := NewFilter()
+	f.Code(AccountPhone, 923)
+
+	sql, values, err := f.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `substring(account.phone from '\((\d+)\)') = $1`, sql)
+	assert.Equal(t, []interface{}{"923"}, values)
+}
+
 func Test_buildAccountUpdateQuery_Success(t *testing.T) {
 	email := domain.FieldEmail("test@test.ru")
 	acc := domain.AccountUpdate{
@@ -120,3 +132,217 @@ func Test_buildCountryInsertQuery_Success(t *testing.T) {
 	assert.Equal(t, expected, sql)
 	assert.Equal(t, 3, len(values))
 }
+
+func Test_buildAccountGroupQuery_Success(t *testing.T) {
+	f := NewFilter()
+	f.Eq(AccountSex, "f")
+	f.Limit = 5
+
+	sql, values, err := buildAccountGroupQuery(f, []string{CountryName, AccountStatus}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SELECT country.name, account.status, COUNT(*) AS count FROM account "
+	expected += "LEFT JOIN country ON country.id = account.country_id "
+	expected += "WHERE account.sex = $1 "
+	expected += "GROUP BY country.name, account.status "
+	expected += `ORDER BY count DESC, country.name COLLATE "C" DESC, account.status COLLATE "C" DESC `
+	expected += "LIMIT 5"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, 1, len(values))
+}
+
+func Test_buildRecommendQuery_Success(t *testing.T) {
+	f := NewFilter()
+	f.Eq(CityName, "Москва")
+	f.Limit = 10
+
+	now := time.Now()
+	target := domain.AccountModel{ID: 1, Sex: "m", Birth: now}
+	sql, values, err := buildRecommendQuery(f, target, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SELECT account.id, account.email, account.status, account.name, account.surname, "
+	expected += "EXTRACT(EPOCH FROM account.birth)::bigint, EXTRACT(EPOCH FROM account.prem_start)::bigint, "
+	expected += "EXTRACT(EPOCH FROM account.prem_end)::bigint FROM account "
+	expected += "JOIN (SELECT interest.account_id, COUNT(DISTINCT interest.name) AS common FROM interest "
+	expected += "WHERE interest.name IN (SELECT name FROM interest WHERE account_id = $1) "
+	expected += "GROUP BY interest.account_id) AS common ON common.account_id = account.id "
+	expected += "LEFT JOIN city ON city.id = account.city_id "
+	expected += "WHERE account.sex <> $2 AND city.name = $3 "
+	expected += "ORDER BY COALESCE(account.prem_start <= $4 AND account.prem_end >= $5, false) DESC, "
+	expected += "CASE account.status WHEN $6 THEN 0 WHEN $7 THEN 1 ELSE 2 END, common.common DESC, "
+	expected += "ABS(EXTRACT(EPOCH FROM account.birth - $8)), account.id "
+	expected += "LIMIT 10"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, []interface{}{int32(1), "m", "Москва", now, now, domain.StatusFree, domain.StatusComplicated, now}, values)
+}
+
+func Test_buildSuggestQuery_Success(t *testing.T) {
+	f := NewFilter()
+	f.Eq(CountryName, "Россия")
+	f.Limit = 5
+
+	target := domain.AccountModel{ID: 1, Sex: "f"}
+	sql, values, err := buildSuggestQuery(f, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similar := "SELECT liker.liker_id, SUM(1 / GREATEST(ABS(liker.ts - target.ts), 1)) AS similarity "
+	similar += "FROM (SELECT likes.likee_id, AVG(EXTRACT(EPOCH FROM likes.ts)) AS ts FROM likes "
+	similar += "WHERE likes.liker_id = $1 GROUP BY likes.likee_id) AS target "
+	similar += "JOIN (SELECT likes.liker_id, likes.likee_id, AVG(EXTRACT(EPOCH FROM likes.ts)) AS ts FROM likes "
+	similar += "WHERE likes.likee_id IN (SELECT likee_id FROM likes WHERE liker_id = $2) "
+	similar += "GROUP BY likes.liker_id, likes.likee_id) AS liker ON liker.likee_id = target.likee_id "
+	similar += "JOIN account ON account.id = liker.liker_id "
+	similar += "LEFT JOIN country ON country.id = account.country_id "
+	similar += "WHERE liker.liker_id <> $3 AND account.sex = $4 AND country.name = $5 "
+	similar += "GROUP BY liker.liker_id"
+
+	expected := "SELECT account.id, account.email, account.status, account.name, account.surname "
+	expected += "FROM (" + similar + ") AS similar "
+	expected += "JOIN likes ON likes.liker_id = similar.liker_id "
+	expected += "JOIN account ON account.id = likes.likee_id "
+	expected += "WHERE likes.likee_id NOT IN (SELECT likee_id FROM likes WHERE liker_id = $6) "
+	expected += "GROUP BY account.id, account.email, account.status, account.name, account.surname "
+	expected += "ORDER BY MAX(similar.similarity) DESC, account.id DESC "
+	expected += "LIMIT 5"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, []interface{}{int32(1), int32(1), int32(1), "f", "Россия", int32(1)}, values)
+}
+
+func Test_FilterContains_DistinctValues(t *testing.T) {
+	f := NewFilter()
+	f.Contains(InterestName, []interface{}{"кино", "кино", "бокс"})
+
+	sql, values, err := f.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "account.id IN (SELECT interest.account_id FROM interest WHERE interest.name IN ($1,$2) "
+	expected += "GROUP BY interest.account_id HAVING COUNT(DISTINCT interest.name) = $3)"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, []interface{}{"кино", "бокс", 2}, values)
+	assert.Empty(t, f.Columns())
+}
+
+func Test_buildAccountSearchQuery_Year(t *testing.T) {
+	f := NewFilter()
+	f.Year(AccountBirth, 1990)
+	f.Limit = 10
+
+	sql, values, err := buildAccountSearchQuery(f, newProjection(f.Columns()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SELECT account.id, account.email, EXTRACT(EPOCH FROM account.birth)::bigint FROM account "
+	expected += "WHERE (account.birth >= $1 AND account.birth < $2) "
+	expected += "ORDER BY account.id DESC "
+	expected += "LIMIT 10"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, []interface{}{
+		time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1991, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, values)
+}
+
+func Test_buildAccountSearchQuery_YearInvalidValue(t *testing.T) {
+	f := NewFilter()
+	f.predicate(AccountBirth, OpYear, "1990")
+	f.Limit = 10
+
+	_, _, err := buildAccountSearchQuery(f, newProjection(f.Columns()))
+	assert.ErrorIs(t, err, errInvalidValue)
+}
+
+func Test_buildAccountUpdateQuery_AllFields(t *testing.T) {
+	now := time.Now().Unix()
+	acc := domain.AccountUpdate{
+		ID:      1,
+		Sex:     (*domain.FieldSex)(util.PtrString("f")),
+		Birth:   (*domain.FieldBirth)(util.PtrInt64(now)),
+		Joined:  (*domain.FieldJoined)(util.PtrInt64(now)),
+		Status:  (*domain.FieldStatus)(util.PtrString("заняты")),
+		Name:    (*domain.FieldFirstname)(util.PtrString("Анна")),
+		Surname: (*domain.FieldSurname)(util.PtrString("Иванова")),
+		Phone:   (*domain.FieldPhone)(util.PtrString("8(999)1234567")),
+		Premium: &domain.PremiumInput{
+			Start: (*domain.FieldPremium)(util.PtrInt64(now)),
+			End:   (*domain.FieldPremium)(util.PtrInt64(now)),
+		},
+	}
+
+	sql, values, err := buildAccountUpdateQuery(acc, uuid.Nil, uuid.Nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "UPDATE account SET birth = $1, joined = $2, name = $3, phone = $4, prem_end = $5, prem_start = $6, "
+	expected += "sex = $7, status = $8, surname = $9 WHERE account.id = $10"
+
+	assert.Equal(t, expected, sql)
+	assert.Equal(t, 10, len(values))
+	assert.Equal(t, acc.Status, values[7])
+}
+
+func Test_buildAccountUpdateQuery_NoFields(t *testing.T) {
+	sql, values, err := buildAccountUpdateQuery(domain.AccountUpdate{ID: 1}, uuid.Nil, uuid.Nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "UPDATE account SET id = id WHERE account.id = $1", sql)
+	assert.Equal(t, 1, len(values))
+}
+
+func Test_buildAccountsCountQuery_Success(t *testing.T) {
+	sql, values, err := buildAccountsCountQuery([]int32{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "SELECT COUNT(*) FROM account WHERE account.id IN ($1,$2)", sql)
+	assert.Equal(t, []interface{}{int32(1), int32(2)}, values)
+}
+
+func Test_buildDatasetStatsQuery_Success(t *testing.T) {
+	sql, values, err := buildDatasetStatsQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "SELECT relname, greatest(reltuples, 0)::bigint FROM pg_class "+
+		"WHERE relkind = $1 AND relname IN ($2,$3,$4) AND pg_table_is_visible(oid)", sql)
+	assert.Equal(t, []interface{}{"r", TableAccount, TableLike, TableInterest}, values)
+}
+
+func Test_buildForeignKeysQuery_Success(t *testing.T) {
+	sql, values, err := buildForeignKeysQuery(TableLike)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "SELECT COUNT(*) FROM pg_constraint WHERE contype = $1 AND conrelid = to_regclass($2)", sql)
+	assert.Equal(t, []interface{}{"f", TableLike}, values)
+}
+
+func Test_buildHasAccountsQuery_Success(t *testing.T) {
+	sql, values, err := buildHasAccountsQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "SELECT EXISTS (SELECT 1 FROM account)", sql)
+	assert.Empty(t, values)
+}
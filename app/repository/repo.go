@@ -3,38 +3,149 @@ package repository
 import (
 	"context"
 	"errors"
-	"log"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"accounts/domain"
+	"accounts/util"
 )
 
-var (
-	errNilModel     = errors.New("nil model (input model wasn't validated probably)")
-	errNotAffected  = errors.New("not affected")
-	errInvalidField = errors.New("invalid field")
-)
+// defaultSQLSample is the share of the statements logged at the debug level, one of 100.
+const defaultSQLSample = 100
 
 type Repository struct {
 	conn *pgxpool.Pool
+
+	logger    *util.Logger
+	sqlSample uint64
+	sqlCount  uint64
 }
 
 func New(conn *pgxpool.Pool) *Repository {
 	return &Repository{
-		conn: conn,
+		conn:      conn,
+		sqlSample: defaultSQLSample,
+	}
+}
+
+// WithLogger sets the logger used if the context doesn't carry one.
+func (r *Repository) WithLogger(l *util.Logger) *Repository {
+	r.logger = l
+	return r
+}
+
+// WithSQLSample logs one of every n statements at the debug level, 1 logs all of them.
+func (r *Repository) WithSQLSample(n int) *Repository {
+	if n > 0 {
+		r.sqlSample = uint64(n)
+	}
+
+	return r
+}
+
+func (r *Repository) FilterAccounts(ctx context.Context, f *Filter) (_ *domain.AccountsOut, err error) {
+	defer wrapError(&err)
+
+	p := newProjection(f.Columns())
+	sql, values, err := buildAccountSearchQuery(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logSQL(ctx, sql, values)
+
+	return r.selectAccounts(ctx, p, sql, values)
+}
+
+// ExplainFilter returns the plan Postgres chose for the filter query.
+func (r *Repository) ExplainFilter(ctx context.Context, f *Filter) (_ string, err error) {
+	defer wrapError(&err)
+
+	sql, values, err := buildAccountSearchQuery(f, newProjection(f.Columns()))
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := r.conn.Query(ctx, "EXPLAIN "+sql, values...)
+	if err != nil {
+		return "", err
+	}
+
+	defer rows.Close()
+
+	var b strings.Builder
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return b.String(), rows.Err()
+}
+
+// DatasetStats is the number of the accounts, the likes and the interests of the storage.
+type DatasetStats struct {
+	Accounts  int64
+	Likes     int64
+	Interests int64
+}
+
+// DatasetStats returns the row estimates of the planner statistics, as counting the likes
+// takes seconds. They read 0 until the tables are analyzed by ApplyDeferred or autovacuum.
+func (r *Repository) DatasetStats(ctx context.Context) (_ DatasetStats, err error) {
+	defer wrapError(&err)
+
+	sql, values, err := buildDatasetStatsQuery()
+	if err != nil {
+		return DatasetStats{}, err
+	}
+
+	rows, err := r.conn.Query(ctx, sql, values...)
+	if err != nil {
+		return DatasetStats{}, err
 	}
+
+	defer rows.Close()
+
+	var stats DatasetStats
+	for rows.Next() {
+		var table string
+		var count int64
+		if err := rows.Scan(&table, &count); err != nil {
+			return DatasetStats{}, err
+		}
+
+		switch table {
+		case TableAccount:
+			stats.Accounts = count
+		case TableLike:
+			stats.Likes = count
+		case TableInterest:
+			stats.Interests = count
+		}
+	}
+
+	return stats, rows.Err()
 }
 
-func (r *Repository) FilterAccounts(ctx context.Context, f *Filter) (*domain.AccountsOut, error) {
-	sql, values, err := buildAccountSearchQuery(f)
+func (r *Repository) GroupAccounts(ctx context.Context, f *Filter, keys []string, asc bool) (_ *domain.GroupsOut, err error) {
+	defer wrapError(&err)
+
+	sql, values, err := buildAccountGroupQuery(f, keys, asc)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Println(sql, values)
+	r.logSQL(ctx, sql, values)
 
 	rows, err := r.conn.Query(ctx, sql, values...)
 	if err != nil {
@@ -43,52 +154,77 @@ func (r *Repository) FilterAccounts(ctx context.Context, f *Filter) (*domain.Acc
 
 	defer rows.Close()
 
-	var acc domain.AccountOut
-	scanFields := make([]interface{}, 0, len(f.Columns()))
-	scanFields = append(scanFields, &acc.ID)
-	scanFields = append(scanFields, &acc.Email)
-	for column := range f.Columns() {
-		switch column {
-		case AccountID, AccountEmail:
-			continue
+	var group domain.GroupOut
+	scanFields := make([]interface{}, 0, len(keys)+1)
+	for _, key := range keys {
+		switch key {
 		case AccountSex:
-			scanFields = append(scanFields, &acc.Sex)
+			scanFields = append(scanFields, &group.Sex)
 		case AccountStatus:
-			scanFields = append(scanFields, &acc.Status)
-		case AccountBirth:
-			scanFields = append(scanFields, &acc.Birth)
-		case AccountFirstname:
-			scanFields = append(scanFields, &acc.Fname)
-		case AccountSurname:
-			scanFields = append(scanFields, &acc.Sname)
-		case AccountPhone:
-			scanFields = append(scanFields, &acc.Phone)
+			scanFields = append(scanFields, &group.Status)
+		case InterestName:
+			scanFields = append(scanFields, &group.Interests)
 		case CountryName:
-			scanFields = append(scanFields, &acc.Country)
+			scanFields = append(scanFields, &group.Country)
 		case CityName:
-			scanFields = append(scanFields, &acc.City)
-		case AccountPremStart:
-			scanFields = append(scanFields, &acc.Premium)
-		case InterestName, LikesLikeeID:
-			continue
+			scanFields = append(scanFields, &group.City)
 		default:
 			return nil, errInvalidField
 		}
 	}
+	scanFields = append(scanFields, &group.Count)
 
-	accounts := []domain.AccountOut{}
+	groups := []domain.GroupOut{}
 	for rows.Next() {
 		if err := rows.Scan(scanFields...); err != nil {
 			return nil, err
 		}
 
-		accounts = append(accounts, acc)
+		groups = append(groups, group)
+	}
+
+	return &domain.GroupsOut{Groups: groups}, rows.Err()
+}
+
+func (r *Repository) RecommendAccounts(ctx context.Context, id int32, f *Filter, now time.Time) (_ *domain.AccountsOut, err error) {
+	defer wrapError(&err)
+
+	target, err := r.selectAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sql, values, err := buildRecommendQuery(f, *target, now)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logSQL(ctx, sql, values)
+
+	return r.selectAccounts(ctx, recommendProjection, sql, values)
+}
+
+func (r *Repository) SuggestAccounts(ctx context.Context, id int32, f *Filter) (_ *domain.AccountsOut, err error) {
+	defer wrapError(&err)
+
+	target, err := r.selectAccount(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
-	return &domain.AccountsOut{Accounts: accounts}, nil
+	sql, values, err := buildSuggestQuery(f, *target)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logSQL(ctx, sql, values)
+
+	return r.selectAccounts(ctx, suggestProjection, sql, values)
 }
 
-func (r *Repository) AddAccount(ctx context.Context, a domain.AccountInput) error {
+func (r *Repository) AddAccount(ctx context.Context, a domain.AccountInput) (err error) {
+	defer wrapError(&err)
+
 	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
 		return err
@@ -96,6 +232,14 @@ func (r *Repository) AddAccount(ctx context.Context, a domain.AccountInput) erro
 
 	defer tx.Rollback(ctx)
 
+	if err = r.checkDuplicates(ctx, int32(*a.ID), (*string)(a.Email), (*string)(a.Phone), tx); err != nil {
+		return err
+	}
+
+	if err = r.checkLikees(ctx, int32(*a.ID), a.LikeModels(), tx); err != nil {
+		return err
+	}
+
 	cityID, err := r.tryInsertCity(ctx, a.CityModel(), tx)
 	if err != nil {
 		return err
@@ -121,7 +265,9 @@ func (r *Repository) AddAccount(ctx context.Context, a domain.AccountInput) erro
 	return tx.Commit(ctx)
 }
 
-func (r *Repository) UpdateAccount(ctx context.Context, a domain.AccountUpdate) error {
+func (r *Repository) UpdateAccount(ctx context.Context, a domain.AccountUpdate) (err error) {
+	defer wrapError(&err)
+
 	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
 		return err
@@ -129,6 +275,23 @@ func (r *Repository) UpdateAccount(ctx context.Context, a domain.AccountUpdate)
 
 	defer tx.Rollback(ctx)
 
+	// an unknown account is reported as such even if its new email or phone is taken
+	if err = r.checkAccountsExist(ctx, []int32{int32(a.ID)}, tx); err != nil {
+		if errors.Is(err, ErrUnknownAccount) {
+			return ErrNotFound
+		}
+
+		return err
+	}
+
+	if err = r.checkDuplicates(ctx, int32(a.ID), (*string)(a.Email), (*string)(a.Phone), tx); err != nil {
+		return err
+	}
+
+	if err = r.checkLikees(ctx, int32(a.ID), a.LikeModels(), tx); err != nil {
+		return err
+	}
+
 	cityID, err := r.tryInsertCity(ctx, a.CityModel(), tx)
 	if err != nil {
 		return err
@@ -143,11 +306,262 @@ func (r *Repository) UpdateAccount(ctx context.Context, a domain.AccountUpdate)
 		return err
 	}
 
+	if interests := a.InterestModels(); interests != nil {
+		if err = r.deleteInterests(ctx, int32(a.ID), tx); err != nil {
+			return err
+		}
+
+		if err = r.tryInsertInterests(ctx, interests, tx); err != nil {
+			return err
+		}
+	}
+
+	if err = r.tryInsertLikes(ctx, a.LikeModels(), tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *Repository) AddLikes(ctx context.Context, likes *domain.LikesInput) (err error) {
+	defer wrapError(&err)
+
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	if err = r.checkAccountsExist(ctx, likes.AccountIDs(), tx); err != nil {
+		return err
+	}
+
+	if err = r.tryInsertLikes(ctx, likes.LikeModels(), tx); err != nil {
+		return err
+	}
+
 	return tx.Commit(ctx)
 }
 
-func (r *Repository) AddLikes(ctx context.Context, likes *domain.LikesInput) error {
-	return r.tryInsertLikes(ctx, likes.LikeModels(), nil)
+// HasAccounts tells whether any account is stored, e.g. by the snapshot loaded on the previous start.
+func (r *Repository) HasAccounts(ctx context.Context) (_ bool, err error) {
+	defer wrapError(&err)
+
+	sql, values, err := buildHasAccountsQuery()
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = r.conn.QueryRow(ctx, sql, values...).Scan(&exists)
+	return exists, err
+}
+
+// LoadAccounts inserts a batch of the snapshot accounts in one transaction copying the rows in bulk.
+// Unlike AddAccount it checks nothing and can't run under the foreign keys, as the likes refer
+// to the accounts of the later batches: they are deferred by migrations.Up until the load is done.
+// It fails before copying anything if the foreign keys of the likes are already applied.
+func (r *Repository) LoadAccounts(ctx context.Context, accounts []domain.AccountInput) (err error) {
+	defer wrapError(&err)
+
+	tx, err := r.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	sql, values, err := buildForeignKeysQuery(TableLike)
+	if err != nil {
+		return err
+	}
+
+	var foreignKeys int
+	if err = tx.QueryRow(ctx, sql, values...).Scan(&foreignKeys); err != nil {
+		return err
+	}
+
+	if foreignKeys > 0 {
+		return errForeignKeys
+	}
+
+	cities, countries := make([]string, 0), make([]string, 0)
+	for _, a := range accounts {
+		if a.City != nil {
+			cities = append(cities, string(*a.City))
+		}
+		if a.Country != nil {
+			countries = append(countries, string(*a.Country))
+		}
+	}
+
+	cityIDs, err := r.resolveNames(ctx, TableCity, CityID, CityName, cities, tx)
+	if err != nil {
+		return err
+	}
+
+	countryIDs, err := r.resolveNames(ctx, TableCountry, CountryID, CountryName, countries, tx)
+	if err != nil {
+		return err
+	}
+
+	models := make([]domain.AccountModel, 0, len(accounts))
+	likes := make([]domain.LikeModel, 0)
+	interests := make([]domain.InterestModel, 0)
+	for _, a := range accounts {
+		var cityID, countryID uuid.UUID
+		if a.City != nil {
+			cityID = cityIDs[string(*a.City)]
+		}
+		if a.Country != nil {
+			countryID = countryIDs[string(*a.Country)]
+		}
+
+		model := a.AccountModel(cityID, countryID)
+		if model == nil {
+			return errNilModel
+		}
+
+		models = append(models, *model)
+		likes = append(likes, a.LikeModels()...)
+		interests = append(interests, a.InterestModels()...)
+	}
+
+	if err = r.copyAccounts(ctx, models, tx); err != nil {
+		return err
+	}
+
+	if err = r.tryInsertLikes(ctx, likes, tx); err != nil {
+		return err
+	}
+
+	if err = r.tryInsertInterests(ctx, interests, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// logSQL logs a sample of the statements, they are built only if the debug level is enabled.
+func (r *Repository) logSQL(ctx context.Context, sql string, values []interface{}) {
+	logger := util.LoggerFrom(ctx, r.logger)
+	if !logger.Enabled(util.LevelDebug) || atomic.AddUint64(&r.sqlCount, 1)%r.sqlSample != 0 {
+		return
+	}
+
+	logger.Debug("sql", "query", sql, "args", values)
+}
+
+func (r *Repository) selectAccounts(ctx context.Context, p *projection, sql string, values []interface{}) (*domain.AccountsOut, error) {
+	rows, err := r.conn.Query(ctx, sql, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	accounts := []domain.AccountOut{}
+	for rows.Next() {
+		acc, err := p.Scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, acc)
+	}
+
+	return &domain.AccountsOut{Accounts: accounts}, rows.Err()
+}
+
+func (r *Repository) selectAccount(ctx context.Context, id int32) (*domain.AccountModel, error) {
+	sql, values, err := buildAccountSelectQuery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var a domain.AccountModel
+	if err = r.conn.QueryRow(ctx, sql, values...).Scan(&a.ID, &a.Sex, &a.Birth); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// checkDuplicates returns a conflict if another account has the same email or phone.
+// Unique constraints catch concurrent duplicates too, but they aren't applied until the data is loaded.
+func (r *Repository) checkDuplicates(ctx context.Context, id int32, email, phone *string, tx pgx.Tx) error {
+	if email == nil && phone == nil {
+		return nil
+	}
+
+	sql, values, err := buildDuplicateQuery(id, email, phone)
+	if err != nil {
+		return err
+	}
+
+	r.logSQL(ctx, sql, values)
+
+	var dupEmail string
+	var dupPhone *string
+	if err = tx.QueryRow(ctx, sql, values...).Scan(&dupEmail, &dupPhone); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+
+		return err
+	}
+
+	if email != nil && dupEmail == *email {
+		return ErrDuplicateEmail
+	}
+
+	return ErrDuplicatePhone
+}
+
+// checkAccountsExist returns an error if any of the accounts doesn't exist,
+// the ids must be distinct.
+func (r *Repository) checkAccountsExist(ctx context.Context, ids []int32, tx pgx.Tx) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sql, values, err := buildAccountsCountQuery(ids)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	if err = tx.QueryRow(ctx, sql, values...).Scan(&count); err != nil {
+		return err
+	}
+
+	if count != len(ids) {
+		return ErrUnknownAccount
+	}
+
+	return nil
+}
+
+// checkLikees returns an error if any of the accounts liked by the account doesn't exist,
+// the account itself may be liked. The foreign keys of the likes may be deferred, so they aren't relied on.
+func (r *Repository) checkLikees(ctx context.Context, id int32, likes []domain.LikeModel, tx pgx.Tx) error {
+	ids := make([]int32, 0, len(likes))
+	seen := make(map[int32]struct{}, len(likes))
+	for _, l := range likes {
+		if _, ok := seen[l.LikeeID]; ok || l.LikeeID == id {
+			continue
+		}
+
+		seen[l.LikeeID] = struct{}{}
+		ids = append(ids, l.LikeeID)
+	}
+
+	return r.checkAccountsExist(ctx, ids, tx)
 }
 
 func (r *Repository) insertAccount(ctx context.Context, a *domain.AccountModel, tx pgx.Tx) error {
@@ -160,11 +574,84 @@ func (r *Repository) insertAccount(ctx context.Context, a *domain.AccountModel,
 		return err
 	}
 
-	log.Println(sql, values)
+	r.logSQL(ctx, sql, values)
 
 	return tx.QueryRow(ctx, sql, values...).Scan(&a.ID)
 }
 
+func (r *Repository) copyAccounts(ctx context.Context, accounts []domain.AccountModel, tx pgx.Tx) error {
+	_, err := tx.CopyFrom(
+		ctx,
+		pgx.Identifier{TableAccount},
+		[]string{shortName(AccountID), shortName(AccountStatus), shortName(AccountEmail),
+			shortName(AccountSex), shortName(AccountBirth), shortName(AccountFirstname),
+			shortName(AccountSurname), shortName(AccountPhone), shortName(AccountCountryID),
+			shortName(AccountCityID), shortName(AccountJoined), shortName(AccountPremStart), shortName(AccountPremEnd)},
+		pgx.CopyFromSlice(len(accounts), func(i int) ([]interface{}, error) {
+			a := accounts[i]
+			return []interface{}{a.ID, a.Status, a.Email, a.Sex, a.Birth, a.Name, a.Surname,
+				a.Phone, a.CountryID, a.CityID, a.Joined, a.PremiumStart, a.PremiumEnd}, nil
+		}),
+	)
+
+	return err
+}
+
+// resolveNames returns the ids of the cities or the countries by name, the missing ones are inserted.
+func (r *Repository) resolveNames(ctx context.Context, table, idColumn, nameColumn string, names []string, tx pgx.Tx) (map[string]uuid.UUID, error) {
+	ids := make(map[string]uuid.UUID)
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	sql, values, err := buildNamesSelectQuery(table, idColumn, nameColumn, names)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+
+		ids[name] = id
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	missing := make([][]interface{}, 0)
+	for _, name := range names {
+		if _, ok := ids[name]; ok {
+			continue
+		}
+
+		ids[name] = uuid.New()
+		missing = append(missing, []interface{}{ids[name], name})
+	}
+
+	if len(missing) == 0 {
+		return ids, nil
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{table}, []string{shortName(idColumn), shortName(nameColumn)}, pgx.CopyFromRows(missing))
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
 func (r *Repository) tryInsertCity(ctx context.Context, c *domain.CityModel, tx pgx.Tx) (id uuid.UUID, err error) {
 	if c == nil {
 		return
@@ -175,7 +662,7 @@ func (r *Repository) tryInsertCity(ctx context.Context, c *domain.CityModel, tx
 		return
 	}
 
-	log.Println(sql, values)
+	r.logSQL(ctx, sql, values)
 
 	err = tx.QueryRow(ctx, sql, values...).Scan(&id)
 	return
@@ -191,7 +678,7 @@ func (r *Repository) tryInsertCountry(ctx context.Context, c *domain.CountryMode
 		return
 	}
 
-	log.Println(sql, values)
+	r.logSQL(ctx, sql, values)
 
 	err = tx.QueryRow(ctx, sql, values...).Scan(&id)
 	return
@@ -203,19 +690,6 @@ func (r *Repository) tryInsertLikes(ctx context.Context, likes []domain.LikeMode
 		return
 	}
 
-	if tx == nil {
-		_, err = r.conn.CopyFrom(
-			ctx,
-			pgx.Identifier{TableLike},
-			[]string{shortName(LikesLikerID), shortName(LikesLikeeID), shortName(LikesTimestamp)},
-			pgx.CopyFromSlice(len(likes), func(i int) ([]interface{}, error) {
-				return []interface{}{likes[i].LikerID, likes[i].LikeeID, likes[i].Timestamp}, nil
-			}),
-		)
-
-		return
-	}
-
 	_, err = tx.CopyFrom(
 		ctx,
 		pgx.Identifier{TableLike},
@@ -246,15 +720,31 @@ func (r *Repository) tryInsertInterests(ctx context.Context, interests []domain.
 	return
 }
 
+func (r *Repository) deleteInterests(ctx context.Context, accountID int32, tx pgx.Tx) error {
+	sql, values, err := buildInterestsDeleteQuery(accountID)
+	if err != nil {
+		return err
+	}
+
+	r.logSQL(ctx, sql, values)
+
+	_, err = tx.Exec(ctx, sql, values...)
+	return err
+}
+
 func (r *Repository) updateAccount(ctx context.Context, a domain.AccountUpdate, cityID, countryID uuid.UUID, tx pgx.Tx) error {
 	sql, values, err := buildAccountUpdateQuery(a, cityID, countryID)
+	if err != nil {
+		return err
+	}
+
 	result, err := tx.Exec(ctx, sql, values...)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return errNotAffected
+		return ErrNotFound
 	}
 
 	return nil
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/domain"
+	"accounts/migrations"
+	"accounts/util"
+)
+
+// testRepository connects to the database from TEST_DB_CONN and recreates the schema,
+// the tests using it are skipped if the variable isn't set.
+func testRepository(t *testing.T) *Repository {
+	connStr := os.Getenv("TEST_DB_CONN")
+	if connStr == "" {
+		t.Skip("TEST_DB_CONN isn't set")
+	}
+
+	ctx := context.Background()
+	conn, err := pgxpool.Connect(ctx, connStr)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	require.NoError(t, migrations.Down(ctx, conn, true))
+	require.NoError(t, migrations.ApplyDeferred(ctx, conn))
+
+	return New(conn)
+}
+
+func testAccountInput(id int32, email, phone string) domain.AccountInput {
+	return domain.AccountInput{
+		ID:     (*domain.FieldID)(util.PtrInt32(id)),
+		Email:  (*domain.FieldEmail)(util.PtrString(email)),
+		Sex:    (*domain.FieldSex)(util.PtrString("m")),
+		Birth:  (*domain.FieldBirth)(util.PtrInt64(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC).Unix())),
+		Joined: (*domain.FieldJoined)(util.PtrInt64(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Unix())),
+		Status: (*domain.FieldStatus)(util.PtrString("свободны")),
+		Phone:  (*domain.FieldPhone)(util.PtrString(phone)),
+	}
+}
+
+func Test_Repository_Duplicates(t *testing.T) {
+	r := testRepository(t)
+	ctx := context.Background()
+
+	add := func(a domain.AccountInput) error {
+		require.NoError(t, a.Validate())
+		return r.AddAccount(ctx, a)
+	}
+
+	update := func(a domain.AccountUpdate) error {
+		require.NoError(t, a.Validate())
+		return r.UpdateAccount(ctx, a)
+	}
+
+	require.NoError(t, add(testAccountInput(1, "one@test.ru", "8(999)0000001")))
+	require.NoError(t, add(testAccountInput(2, "two@test.ru", "8(999)0000002")))
+
+	err := add(testAccountInput(3, "one@test.ru", "8(999)0000003"))
+	assert.Equal(t, domain.ErrorConflict, domain.KindOf(err))
+	assert.Equal(t, ErrDuplicateEmail, err)
+
+	err = add(testAccountInput(3, "three@test.ru", "8(999)0000002"))
+	assert.Equal(t, domain.ErrorConflict, domain.KindOf(err))
+	assert.Equal(t, ErrDuplicatePhone, err)
+
+	err = update(domain.AccountUpdate{ID: 2, Email: (*domain.FieldEmail)(util.PtrString("one@test.ru"))})
+	assert.Equal(t, ErrDuplicateEmail, err)
+
+	err = update(domain.AccountUpdate{ID: 2, Phone: (*domain.FieldPhone)(util.PtrString("8(999)0000001"))})
+	assert.Equal(t, ErrDuplicatePhone, err)
+
+	// an account keeping its own email or phone isn't a duplicate
+	err = update(domain.AccountUpdate{
+		ID:    1,
+		Email: (*domain.FieldEmail)(util.PtrString("one@test.ru")),
+		Phone: (*domain.FieldPhone)(util.PtrString("8(999)0000001")),
+	})
+	assert.NoError(t, err)
+
+	err = update(domain.AccountUpdate{ID: 100, Email: (*domain.FieldEmail)(util.PtrString("new@test.ru"))})
+	assert.Equal(t, domain.ErrorNotFound, domain.KindOf(err))
+
+	// an unknown account isn't found even if the email is taken
+	err = update(domain.AccountUpdate{ID: 100, Email: (*domain.FieldEmail)(util.PtrString("one@test.ru"))})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func Test_Repository_AddLikes(t *testing.T) {
+	r := testRepository(t)
+	ctx := context.Background()
+
+	for _, a := range []domain.AccountInput{
+		testAccountInput(1, "one@test.ru", "8(999)0000001"),
+		testAccountInput(2, "two@test.ru", "8(999)0000002"),
+	} {
+		require.NoError(t, a.Validate())
+		require.NoError(t, r.AddAccount(ctx, a))
+	}
+
+	like := func(liker, likee int32) domain.LikeInput {
+		return domain.LikeInput{
+			Liker:     (*domain.FieldID)(util.PtrInt32(liker)),
+			Likee:     (*domain.FieldID)(util.PtrInt32(likee)),
+			Timestamp: (*domain.FieldTimestamp)(util.PtrInt64(time.Now().Unix())),
+		}
+	}
+
+	countLikes := func() int {
+		var count int
+		require.NoError(t, r.conn.QueryRow(ctx, "SELECT COUNT(*) FROM likes").Scan(&count))
+		return count
+	}
+
+	// the whole batch is rejected because of a single unknown account
+	likes := &domain.LikesInput{Likes: []domain.LikeInput{like(1, 2), like(2, 100)}}
+	require.NoError(t, likes.Validate())
+	err := r.AddLikes(ctx, likes)
+	assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+	assert.Equal(t, 0, countLikes())
+
+	likes = &domain.LikesInput{Likes: []domain.LikeInput{like(1, 2), like(2, 1)}}
+	require.NoError(t, likes.Validate())
+	require.NoError(t, r.AddLikes(ctx, likes))
+	assert.Equal(t, 2, countLikes())
+}
+
+func Test_Repository_LoadAccounts_ForeignKeys(t *testing.T) {
+	r := testRepository(t)
+	ctx := context.Background()
+
+	liker := testAccountInput(1, "one@test.ru", "8(999)0000001")
+	liker.Likes = []*domain.AccountLikeInput{{
+		UserID:    (*domain.FieldID)(util.PtrInt32(2)),
+		Timestamp: (*domain.FieldTimestamp)(util.PtrInt64(time.Now().Unix())),
+	}}
+	require.NoError(t, liker.Validate())
+
+	// the constraints are applied by testRepository, the like of the later account would violate them
+	err := r.LoadAccounts(ctx, []domain.AccountInput{liker})
+	assert.ErrorIs(t, err, errForeignKeys)
+
+	require.NoError(t, migrations.Down(ctx, r.conn, true))
+	require.NoError(t, migrations.Up(ctx, r.conn))
+	require.NoError(t, r.LoadAccounts(ctx, []domain.AccountInput{liker}))
+	require.NoError(t, r.LoadAccounts(ctx, []domain.AccountInput{testAccountInput(2, "two@test.ru", "8(999)0000002")}))
+	require.NoError(t, migrations.ApplyDeferred(ctx, r.conn))
+}
+
+// Test_Repository_HasAccounts checks the restart of the server: the accounts are found
+// once the snapshot is loaded and the deferred constraints are applied.
+func Test_Repository_HasAccounts(t *testing.T) {
+	r := testRepository(t)
+	ctx := context.Background()
+	require.NoError(t, migrations.Down(ctx, r.conn, true))
+	require.NoError(t, migrations.Up(ctx, r.conn))
+
+	has, err := r.HasAccounts(ctx)
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	a := testAccountInput(1, "one@test.ru", "8(999)0000001")
+	require.NoError(t, a.Validate())
+	require.NoError(t, r.LoadAccounts(ctx, []domain.AccountInput{a}))
+	require.NoError(t, migrations.ApplyDeferred(ctx, r.conn))
+
+	// on the restart the migrations are applied already and the snapshot is skipped
+	require.NoError(t, migrations.Up(ctx, r.conn))
+	has, err = r.HasAccounts(ctx)
+	require.NoError(t, err)
+	assert.True(t, has)
+	require.NoError(t, migrations.ApplyDeferred(ctx, r.conn))
+}
+
+// Test_Repository_UnknownLikees checks that the likes of unknown accounts are rejected
+// without the foreign keys, as they are deferred until the snapshot is loaded.
+func Test_Repository_UnknownLikees(t *testing.T) {
+	r := testRepository(t)
+	ctx := context.Background()
+	require.NoError(t, migrations.Down(ctx, r.conn, true))
+	require.NoError(t, migrations.Up(ctx, r.conn))
+
+	likes := func(ids ...int32) []*domain.AccountLikeInput {
+		out := make([]*domain.AccountLikeInput, 0, len(ids))
+		for _, id := range ids {
+			out = append(out, &domain.AccountLikeInput{
+				UserID:    (*domain.FieldID)(util.PtrInt32(id)),
+				Timestamp: (*domain.FieldTimestamp)(util.PtrInt64(time.Now().Unix())),
+			})
+		}
+
+		return out
+	}
+
+	add := func(a domain.AccountInput) error {
+		require.NoError(t, a.Validate())
+		return r.AddAccount(ctx, a)
+	}
+
+	require.NoError(t, add(testAccountInput(1, "one@test.ru", "8(999)0000001")))
+
+	a := testAccountInput(2, "two@test.ru", "8(999)0000002")
+	a.Likes = likes(1, 100)
+	assert.Equal(t, ErrUnknownAccount, add(a))
+
+	// the account may like itself
+	a.Likes = likes(1, 2, 1)
+	require.NoError(t, add(a))
+
+	u := domain.AccountUpdate{ID: 1, Likes: likes(2, 100)}
+	require.NoError(t, u.Validate())
+	assert.Equal(t, ErrUnknownAccount, r.UpdateAccount(ctx, u))
+
+	require.NoError(t, migrations.ApplyDeferred(ctx, r.conn))
+}
@@ -2,45 +2,197 @@ package app
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v4/pgxpool"
 
 	"accounts/app/controller"
+	"accounts/app/memory"
+	"accounts/app/metrics"
 	"accounts/app/repository"
 	"accounts/app/service"
+	"accounts/migrations"
+	"accounts/util"
 )
 
+// Serve runs the server configured by the command line and the environment until SIGINT or SIGTERM.
 func Serve() error {
-	connStr := flag.String("conn", "", "connection string")
-	flag.Parse()
-	if connStr == nil || *connStr == "" {
-		return fmt.Errorf("connection string is empty")
+	config, err := ReadConfig(os.Args[1:])
+	if err != nil {
+		return err
 	}
 
-	conn, err := pgxpool.Connect(context.Background(), *connStr)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return Run(ctx, config)
+}
+
+// Run prepares the storage and serves the requests until the context is done,
+// then it drains the requests in flight and closes the storage.
+func Run(ctx context.Context, config *Config) error {
+	level, err := util.ParseLevel(config.LogLevel)
 	if err != nil {
 		return err
 	}
 
-	router := Router(
-		controller.New(
-			service.New(
-				repository.New(conn),
-			),
-		),
-	)
+	logger := util.NewLogger(os.Stderr, level)
+	m := metrics.New()
+
+	var accountService *service.AccountService
+	var loader snapshotLoader
+	afterLoad := func() error { return nil }
+	switch config.Storage {
+	case storagePostgres:
+		conn, err := connect(ctx, config)
+		if err != nil {
+			return err
+		}
+
+		defer conn.Close()
+
+		if config.Migrate {
+			migrateCtx := util.WithLogger(ctx, logger)
+			if err = migrations.Up(migrateCtx, conn); err != nil {
+				return err
+			}
+
+			// the constraints and the indexes are created once the snapshot is loaded
+			afterLoad = func() error {
+				return migrations.ApplyDeferred(migrateCtx, conn)
+			}
+		}
+
+		repo := repository.New(conn).WithLogger(logger).WithSQLSample(config.SQLLogSample)
+		accountService = service.New(repo)
+		loader = repo
+		if err = m.Register(metrics.NewPoolCollector(conn), metrics.NewDatasetCollector(repo)); err != nil {
+			return err
+		}
+	case storageMemory:
+		mem := memory.New().WithMaxID(int32(config.MemoryMaxID))
+		accountService = service.New(mem)
+		loader = mem
+		if err = m.Register(metrics.NewDatasetCollector(mem)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown storage %q", config.Storage)
+	}
+
+	accountService.WithLogger(logger).WithOperatorCounter(m)
+
+	if options, err := ReadOptions(config.OptionsPath); err == nil {
+		accountService.WithNow(options.Now)
+		logger.Info("options loaded", "now", options.Now, "mode", options.Mode)
+	} else {
+		logger.Warn("options aren't loaded, the wall clock is used", "error", err)
+	}
+
+	if config.SnapshotPath != "" {
+		start := time.Now()
+		loaded, err := LoadSnapshot(ctx, config.SnapshotPath, loader)
+		switch {
+		case errors.Is(err, ErrSnapshotSkipped):
+			// a restart of the server keeps the accounts of postgres loaded before
+			logger.Info("snapshot skipped", "reason", err, "path", config.SnapshotPath)
+		case err != nil:
+			return fmt.Errorf("snapshot isn't loaded: %w", err)
+		default:
+			logger.Info("snapshot loaded", "accounts", loaded, "path", config.SnapshotPath, "duration", time.Since(start))
+		}
+	}
+
+	if err := afterLoad(); err != nil {
+		return err
+	}
+
+	accountController := controller.New(accountService).
+		WithConflictStatus(config.ConflictStatus).
+		WithLogger(logger)
+	if config.ErrorBody {
+		accountController.WithErrorBody()
+	}
+
+	server := &http.Server{
+		Addr:         config.Addr,
+		Handler:      Router(accountController, logger, m),
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+
+	return serve(ctx, server, config.ShutdownTimeout, logger)
+}
+
+// serve listens until the context is done and shuts the server down gracefully,
+// the requests in flight are given shutdownTimeout to complete.
+func serve(ctx context.Context, server *http.Server, shutdownTimeout time.Duration, logger *util.Logger) error {
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", server.Addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-errs; err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func connect(ctx context.Context, config *Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.ConnStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.PoolMinConns > 0 {
+		poolConfig.MinConns = int32(config.PoolMinConns)
+	}
+
+	if config.PoolMaxConns > 0 {
+		poolConfig.MaxConns = int32(config.PoolMaxConns)
+	}
 
-	return http.ListenAndServe("0.0.0.0:8888", router)
+	return pgxpool.ConnectConfig(ctx, poolConfig)
 }
 
-func Router(c Controller) http.Handler {
+// Router routes the requests to the controller, logging each of them with its request id.
+// The metrics of the requests are served on /metrics unless m is nil.
+func Router(c Controller, logger *util.Logger, m *metrics.Metrics) http.Handler {
 	router := chi.NewRouter()
+	router.Use(middleware.RequestID, requestLogger(logger, slowRequest), m.Middleware)
+	if m != nil {
+		router.Get("/metrics", m.Handler().ServeHTTP)
+	}
+
 	router.Route("/accounts", func(r chi.Router) {
 		r.Get("/filter/", c.FilterAccounts)
+		r.Get("/filter/explain/", c.ExplainFilter)
 		r.Get("/group/", c.GroupAccounts)
 		r.Get("/{id}/recommend/", c.GetRecommends)
 		r.Get("/{id}/suggest/", c.GetSuggestions)
@@ -54,6 +206,7 @@ func Router(c Controller) http.Handler {
 
 type Controller interface {
 	FilterAccounts(w http.ResponseWriter, r *http.Request)
+	ExplainFilter(w http.ResponseWriter, r *http.Request)
 	GroupAccounts(w http.ResponseWriter, r *http.Request)
 	GetRecommends(w http.ResponseWriter, r *http.Request)
 	GetSuggestions(w http.ResponseWriter, r *http.Request)
@@ -2,9 +2,11 @@ package service
 
 import (
 	"fmt"
+	"time"
 
 	"accounts/app/repository"
 	repo "accounts/app/repository"
+	"accounts/util"
 )
 
 var qpOnColumns = map[string]string{
@@ -17,19 +19,23 @@ var qpOnColumns = map[string]string{
 	qpCountry:   repo.CountryName,
 	qpCity:      repo.CityName,
 	qpBirth:     repo.AccountBirth,
+	qpJoined:    repo.AccountJoined,
 	qpInterests: repo.InterestName,
-	qpLikes:     repo.LikesLikerID,     // ?
-	qpPremium:   repo.AccountPremStart, // ?
+	qpLikes:     repo.LikesLikeeID,
+	qpPremium:   repo.AccountPremStart,
 }
 
-func BuildFilter(params map[string]QueryParam) (*repo.Filter, error) {
+// BuildFilter converts query params to the filter planned from the most selective param,
+// now is the time premium_now is checked at.
+func BuildFilter(params map[string]QueryParam, now time.Time) (*repo.Filter, error) {
 	filter := repository.NewFilter()
 	limit := params[qpLimit].Values[0].(int)
 	delete(params, qpLimit)
 	filter.Limit = limit
 
-	for _, param := range params {
+	for _, param := range planParams(params) {
 		column := qpOnColumns[param.Field]
+		filter.Step(paramName(param), estimateSelectivity(param))
 
 		if param.Op == nil {
 			filter.Eq(column, param.Values[0])
@@ -44,9 +50,9 @@ func BuildFilter(params map[string]QueryParam) (*repo.Filter, error) {
 		case opEq:
 			filter.Eq(column, param.Values[0])
 		case opLt:
-			filter.Lt(column, param.Values[0])
+			filter.Lt(column, timestampValue(param.Values[0]))
 		case opGt:
-			filter.Gt(column, param.Values[0])
+			filter.Gt(column, timestampValue(param.Values[0]))
 		case opNeq:
 			filter.Neq(column, param.Values[0])
 		case opAny:
@@ -54,15 +60,19 @@ func BuildFilter(params map[string]QueryParam) (*repo.Filter, error) {
 		case opDomain:
 			filter.Domain(column, param.Values[0])
 		case opNull:
+			if param.Field == qpPremium {
+				filter.Null(repo.AccountPremEnd, param.Values[0].(bool))
+			}
+
 			filter.Null(column, param.Values[0].(bool))
 		case opStarts:
 			filter.Starts(column, param.Values[0])
 		case opCode:
 			filter.Code(column, param.Values[0])
 		case opYear:
-			filter.Year(column, param.Values[0])
+			filter.Year(column, param.Values[0].(int))
 		case opNow:
-			filter.Now(column)
+			filter.Now(now, param.Values[0].(bool))
 		case opContains:
 			filter.Contains(column, param.Values)
 		}
@@ -70,3 +80,12 @@ func BuildFilter(params map[string]QueryParam) (*repo.Filter, error) {
 
 	return filter, nil
 }
+
+// timestampValue converts unix timestamps to time to compare them with timestamp columns.
+func timestampValue(value interface{}) interface{} {
+	if ts, ok := value.(int64); ok {
+		return *util.TimestampToDatetime(&ts)
+	}
+
+	return value
+}
@@ -3,8 +3,10 @@ package service
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"accounts/util"
 )
@@ -34,7 +36,7 @@ func Test_BuildFilter(t *testing.T) {
 					Op:     util.PtrString(opLt),
 				},
 			},
-			Expected: "account.email < $1",
+			Expected: `account.email COLLATE "C" < $1`,
 		},
 		{
 			Params: map[string]QueryParam{
@@ -44,7 +46,7 @@ func Test_BuildFilter(t *testing.T) {
 					Op:     util.PtrString(opDomain),
 				},
 			},
-			Expected: fmt.Sprintf("account.email LIKE $1"),
+			Expected: fmt.Sprintf("split_part(account.email, '@', 2) = $1"),
 		},
 		{
 			Params: map[string]QueryParam{
@@ -104,7 +106,7 @@ func Test_BuildFilter(t *testing.T) {
 					Op:     util.PtrString(opCode),
 				},
 			},
-			Expected: "account.phone LIKE $1",
+			Expected: `substring(account.phone from '\((\d+)\)') = $1`,
 		},
 		{
 			Params: map[string]QueryParam{
@@ -114,7 +116,7 @@ func Test_BuildFilter(t *testing.T) {
 					Op:     util.PtrString(opCode),
 				},
 			},
-			Expected: "account.phone LIKE $1",
+			Expected: `substring(account.phone from '\((\d+)\)') = $1`,
 		},
 		{
 			Params: map[string]QueryParam{
@@ -134,19 +136,80 @@ func Test_BuildFilter(t *testing.T) {
 					Op:     util.PtrString(opContains),
 				},
 			},
-			Expected: "likes.liker_id IN ($1,$2,$3)",
+			Expected: "account.id IN (SELECT likes.liker_id FROM likes WHERE likes.likee_id IN ($1,$2,$3) " +
+				"GROUP BY likes.liker_id HAVING COUNT(DISTINCT likes.likee_id) = $4)",
+		},
+		{
+			Params: map[string]QueryParam{
+				"interests": {
+					Field:  "interests",
+					Values: []interface{}{"бокс", "кино"},
+					Op:     util.PtrString(opContains),
+				},
+			},
+			Expected: "account.id IN (SELECT interest.account_id FROM interest WHERE interest.name IN ($1,$2) " +
+				"GROUP BY interest.account_id HAVING COUNT(DISTINCT interest.name) = $3)",
+		},
+		{
+			Params: map[string]QueryParam{
+				"interests": {
+					Field:  "interests",
+					Values: []interface{}{"бокс", "кино"},
+					Op:     util.PtrString(opAny),
+				},
+			},
+			Expected: "account.id IN (SELECT interest.account_id FROM interest WHERE interest.name IN ($1,$2))",
 		},
 		{
 			Params: map[string]QueryParam{
 				"premium": {
 					Field:  "premium",
-					Values: []interface{}{1},
+					Values: []interface{}{true},
 					Op:     util.PtrString(opNow),
 				},
 			},
 			Expected: "(account.prem_start <= $1 AND account.prem_end >= $2)",
 		},
-		// TODO: test opYear
+		{
+			Params: map[string]QueryParam{
+				"premium": {
+					Field:  "premium",
+					Values: []interface{}{false},
+					Op:     util.PtrString(opNow),
+				},
+			},
+			Expected: "(account.prem_start IS NULL OR account.prem_start > $1 OR account.prem_end < $2)",
+		},
+		{
+			Params: map[string]QueryParam{
+				"premium": {
+					Field:  "premium",
+					Values: []interface{}{true},
+					Op:     util.PtrString(opNull),
+				},
+			},
+			Expected: "account.prem_end IS NULL AND account.prem_start IS NULL",
+		},
+		{
+			Params: map[string]QueryParam{
+				"birth": {
+					Field:  "birth",
+					Values: []interface{}{1990},
+					Op:     util.PtrString(opYear),
+				},
+			},
+			Expected: "(account.birth >= $1 AND account.birth < $2)",
+		},
+		{
+			Params: map[string]QueryParam{
+				"joined": {
+					Field:  "joined",
+					Values: []interface{}{2015},
+					Op:     util.PtrString(opYear),
+				},
+			},
+			Expected: "(account.joined >= $1 AND account.joined < $2)",
+		},
 	}
 
 	for _, tc := range testcases {
@@ -155,7 +218,7 @@ func Test_BuildFilter(t *testing.T) {
 			Values: []interface{}{10},
 		}
 
-		filter, err := BuildFilter(tc.Params)
+		filter, err := BuildFilter(tc.Params, time.Now())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -168,3 +231,19 @@ func Test_BuildFilter(t *testing.T) {
 		assert.Equal(t, tc.Expected, sql)
 	}
 }
+
+func Test_BuildFilter_BirthTimestamps(t *testing.T) {
+	ts := time.Date(1990, 5, 5, 0, 0, 0, 0, time.UTC)
+	params := map[string]QueryParam{
+		qpLimit: {Field: qpLimit, Values: []interface{}{10}},
+		qpBirth: {Field: qpBirth, Values: []interface{}{ts.Unix()}, Op: util.PtrString(opLt)},
+	}
+
+	filter, err := BuildFilter(params, time.Now())
+	require.NoError(t, err)
+
+	sql, values, err := filter.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "account.birth < $1", sql)
+	assert.Equal(t, []interface{}{ts}, values)
+}
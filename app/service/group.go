@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	repo "accounts/app/repository"
+	"accounts/util"
+)
+
+var groupKeyColumns = map[string]string{
+	qpSex:       repo.AccountSex,
+	qpStatus:    repo.AccountStatus,
+	qpInterests: repo.InterestName,
+	qpCountry:   repo.CountryName,
+	qpCity:      repo.CityName,
+}
+
+// parseGroupKeys returns the columns to group by in the order they were requested.
+func parseGroupKeys(qps url.Values) ([]string, error) {
+	values, ok := qps[qpKeys]
+	if !ok {
+		return nil, fmt.Errorf(errMissingRequiredParam, qpKeys)
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf(errValuesLen, len(values))
+	}
+
+	keys := strings.Split(values[0], ",")
+	columns := make([]string, 0, len(keys))
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		column, ok := groupKeyColumns[key]
+		if !ok {
+			return nil, fmt.Errorf(errInvalidValue, key)
+		}
+
+		if _, ok := seen[column]; ok {
+			return nil, fmt.Errorf(errInvalidValue, key)
+		}
+
+		seen[column] = struct{}{}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// parseGroupOrder returns true for ascending order (order=1) and false for descending (order=-1).
+func parseGroupOrder(qps url.Values) (bool, error) {
+	values, ok := qps[qpOrder]
+	if !ok {
+		return false, fmt.Errorf(errMissingRequiredParam, qpOrder)
+	}
+
+	if len(values) != 1 {
+		return false, fmt.Errorf(errValuesLen, len(values))
+	}
+
+	order, err := util.ParseInt(values[0])
+	if err != nil {
+		return false, err
+	}
+
+	if order != 1 && order != -1 {
+		return false, fmt.Errorf(errInvalidValue, order)
+	}
+
+	return order == 1, nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	repo "accounts/app/repository"
+)
+
+func Test_parseGroupParams_Success(t *testing.T) {
+	qps := url.Values{
+		qpKeys:    {"city,sex"},
+		qpOrder:   {"-1"},
+		qpLimit:   {"10"},
+		qpQueryID: {"1"},
+		qpBirth:   {"1990"},
+		qpLikes:   {"100"},
+	}
+
+	keys, err := parseGroupKeys(qps)
+	require.NoError(t, err)
+	assert.Equal(t, []string{repo.CityName, repo.AccountSex}, keys)
+
+	asc, err := parseGroupOrder(qps)
+	require.NoError(t, err)
+	assert.False(t, asc)
+
+	params, err := ParseQueryParams(qps, false)
+	require.NoError(t, err)
+	assert.Equal(t, opYear, *params[qpBirth].Op)
+	assert.Equal(t, []interface{}{1990}, params[qpBirth].Values)
+	assert.Equal(t, opContains, *params[qpLikes].Op)
+	assert.Equal(t, []interface{}{100}, params[qpLikes].Values)
+}
+
+func Test_parseGroupParams_Fail(t *testing.T) {
+	testcases := []url.Values{
+		{qpOrder: {"1"}},
+		{qpKeys: {"email"}, qpOrder: {"1"}},
+		{qpKeys: {"sex,sex"}, qpOrder: {"1"}},
+		{qpKeys: {"sex"}, qpOrder: {"0"}},
+		{qpKeys: {"sex"}},
+	}
+
+	for _, qps := range testcases {
+		_, errKeys := parseGroupKeys(qps)
+		_, errOrder := parseGroupOrder(qps)
+		assert.True(t, errKeys != nil || errOrder != nil, "%v", qps)
+	}
+}
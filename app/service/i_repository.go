@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"accounts/app/repository"
 	"accounts/domain"
@@ -9,6 +10,10 @@ import (
 
 type accountRepo interface {
 	FilterAccounts(ctx context.Context, filter *repository.Filter) (*domain.AccountsOut, error)
+	ExplainFilter(ctx context.Context, filter *repository.Filter) (string, error)
+	GroupAccounts(ctx context.Context, filter *repository.Filter, keys []string, asc bool) (*domain.GroupsOut, error)
+	RecommendAccounts(ctx context.Context, id int32, filter *repository.Filter, now time.Time) (*domain.AccountsOut, error)
+	SuggestAccounts(ctx context.Context, id int32, filter *repository.Filter) (*domain.AccountsOut, error)
 	AddAccount(ctx context.Context, a domain.AccountInput) error
 	UpdateAccount(ctx context.Context, a domain.AccountUpdate) error
 	AddLikes(ctx context.Context, likes *domain.LikesInput) error
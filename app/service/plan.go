@@ -0,0 +1,97 @@
+package service
+
+import (
+	"math"
+	"sort"
+)
+
+const unknownSelectivity = 1
+
+// selectivities are the shares of the accounts matching a param with one value,
+// estimated on the contest dataset. A param without an operation is estimated as eq.
+var selectivities = map[string]float64{
+	qpSex + "_" + opEq:             0.5,
+	qpEmail + "_" + opEq:           0.000001,
+	qpEmail + "_" + opDomain:       0.08,
+	qpEmail + "_" + opLt:           0.5,
+	qpEmail + "_" + opGt:           0.5,
+	qpStatus + "_" + opEq:          0.33,
+	qpStatus + "_" + opNeq:         0.67,
+	qpFirstname + "_" + opEq:       0.01,
+	qpFirstname + "_" + opAny:      0.01,
+	qpFirstname + "_" + opNull:     0.25,
+	qpSurname + "_" + opEq:         0.001,
+	qpSurname + "_" + opStarts:     0.02,
+	qpSurname + "_" + opNull:       0.3,
+	qpPhone + "_" + opEq:           0.000001,
+	qpPhone + "_" + opCode:         0.01,
+	qpPhone + "_" + opNull:         0.5,
+	qpCountry + "_" + opEq:         0.015,
+	qpCountry + "_" + opNull:       0.2,
+	qpCity + "_" + opEq:            0.002,
+	qpCity + "_" + opAny:           0.002,
+	qpCity + "_" + opNull:          0.3,
+	qpBirth + "_" + opLt:           0.5,
+	qpBirth + "_" + opGt:           0.5,
+	qpBirth + "_" + opYear:         0.02,
+	qpJoined + "_" + opYear:        0.14,
+	qpInterests + "_" + opAny:      0.1,
+	qpInterests + "_" + opContains: 0.1,
+	qpLikes + "_" + opContains:     0.00002,
+	qpPremium + "_" + opNow:        0.05,
+	qpPremium + "_" + opNull:       0.7,
+}
+
+func paramName(param QueryParam) string {
+	if param.Op == nil {
+		return param.Field
+	}
+
+	return param.Field + "_" + *param.Op
+}
+
+// estimateSelectivity returns the estimated share of the accounts matching the param.
+func estimateSelectivity(param QueryParam) float64 {
+	op := opEq
+	if param.Op != nil {
+		op = *param.Op
+	}
+
+	selectivity, ok := selectivities[param.Field+"_"+op]
+	if !ok {
+		return unknownSelectivity
+	}
+
+	switch op {
+	case opAny:
+		return math.Min(1, selectivity*float64(len(param.Values)))
+	case opContains:
+		return math.Pow(selectivity, float64(len(param.Values)))
+	case opNull, opNow:
+		if matching, ok := param.Values[0].(bool); ok && !matching {
+			return 1 - selectivity
+		}
+	}
+
+	return selectivity
+}
+
+// planParams orders the params from the most selective one,
+// so that a storage evaluating the filter step by step narrows the candidates as early as possible.
+func planParams(params map[string]QueryParam) []QueryParam {
+	planned := make([]QueryParam, 0, len(params))
+	for _, param := range params {
+		planned = append(planned, param)
+	}
+
+	sort.Slice(planned, func(i, j int) bool {
+		a, b := estimateSelectivity(planned[i]), estimateSelectivity(planned[j])
+		if a != b {
+			return a < b
+		}
+
+		return planned[i].Field < planned[j].Field
+	})
+
+	return planned
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/util"
+)
+
+func Test_estimateSelectivity(t *testing.T) {
+	testcases := []struct {
+		param    QueryParam
+		expected float64
+	}{
+		{QueryParam{Field: qpSex, Op: util.PtrString(opEq), Values: []interface{}{"m"}}, 0.5},
+		{QueryParam{Field: qpSex, Values: []interface{}{"m"}}, 0.5},
+		{QueryParam{Field: qpCity, Op: util.PtrString(opAny), Values: []interface{}{"a", "b", "c"}}, 0.006},
+		{QueryParam{Field: qpInterests, Op: util.PtrString(opContains), Values: []interface{}{"a", "b"}}, 0.01},
+		{QueryParam{Field: qpPremium, Op: util.PtrString(opNull), Values: []interface{}{true}}, 0.7},
+		{QueryParam{Field: qpPremium, Op: util.PtrString(opNull), Values: []interface{}{false}}, 0.3},
+		{QueryParam{Field: qpPremium, Values: []interface{}{int64(1)}}, unknownSelectivity},
+	}
+
+	for _, tc := range testcases {
+		assert.InDelta(t, tc.expected, estimateSelectivity(tc.param), 1e-9, paramName(tc.param))
+	}
+}
+
+func Test_BuildFilter_MostSelectiveFirst(t *testing.T) {
+	params := map[string]QueryParam{
+		qpLimit:  {Field: qpLimit, Values: []interface{}{10}},
+		qpSex:    {Field: qpSex, Op: util.PtrString(opEq), Values: []interface{}{"m"}},
+		qpCity:   {Field: qpCity, Op: util.PtrString(opEq), Values: []interface{}{"Москва"}},
+		qpStatus: {Field: qpStatus, Op: util.PtrString(opNeq), Values: []interface{}{"заняты"}},
+		qpBirth:  {Field: qpBirth, Op: util.PtrString(opYear), Values: []interface{}{1990}},
+	}
+
+	filter, err := BuildFilter(params, time.Now())
+	require.NoError(t, err)
+
+	steps := make([]string, 0)
+	for _, step := range filter.Steps() {
+		steps = append(steps, step.Param)
+	}
+
+	assert.Equal(t, []string{"city_eq", "birth_year", "sex_eq", "status_neq"}, steps)
+
+	sql, _, err := filter.Build()
+	require.NoError(t, err)
+	assert.Equal(t, "city.name = $1 AND (account.birth >= $2 AND account.birth < $3) AND account.sex = $4 AND account.status <> $5", sql)
+}
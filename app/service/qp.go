@@ -45,9 +45,12 @@ const (
 	qpInterests = "interests"
 	qpLikes     = "likes"
 	qpPremium   = "premium"
+	qpJoined    = "joined"
 
 	qpLimit   = "limit"
 	qpQueryID = "query_id"
+	qpKeys    = "keys"
+	qpOrder   = "order"
 )
 
 var (
@@ -64,6 +67,15 @@ var (
 		qpInterests: parseInterests,
 		qpLikes:     parseLikes,
 		qpPremium:   parsePremium,
+		qpJoined:    parseJoined,
+	}
+
+	// operations implied by params passed without an explicit operation
+	implicitOps = map[string]string{
+		qpBirth:     opYear,
+		qpJoined:    opYear,
+		qpInterests: opContains,
+		qpLikes:     opContains,
 	}
 )
 
@@ -96,6 +108,10 @@ func ParseQueryParams(qps url.Values, withOp bool) (map[string]QueryParam, error
 			continue
 		}
 
+		if !withOp && (param == qpKeys || param == qpOrder) {
+			continue
+		}
+
 		if len(values) != 1 {
 			return nil, fmt.Errorf(errValuesLen, len(values))
 		}
@@ -119,7 +135,11 @@ func parseQueryParam(param string, strValues []string, withOp bool) (qp QueryPar
 		}
 
 		qp.Field = param
-		qp.Values, err = parser(nil, strValues)
+		if op, ok := implicitOps[param]; ok {
+			qp.Op = util.PtrString(op)
+		}
+
+		qp.Values, err = parser(qp.Op, strValues)
 		return
 	}
 
@@ -138,6 +158,43 @@ func parseQueryParam(param string, strValues []string, withOp bool) (qp QueryPar
 	return
 }
 
+// checkParams returns an error if there is a param except limit and the allowed ones.
+func checkParams(params map[string]QueryParam, allowed ...string) error {
+	for field := range params {
+		if field == qpLimit {
+			continue
+		}
+
+		found := false
+		for _, a := range allowed {
+			if field == a {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf(errInvalidParam, field)
+		}
+	}
+
+	return nil
+}
+
+func parseAccountID(s string) (int32, error) {
+	id, err := util.ParseInt(s)
+	if err != nil {
+		return 0, err
+	}
+
+	accountID := domain.FieldID(id)
+	if err = accountID.Validate(); err != nil {
+		return 0, err
+	}
+
+	return int32(accountID), nil
+}
+
 func parseLimit(qps url.Values) (QueryParam, error) {
 	if _, ok := qps[qpLimit]; !ok {
 		return QueryParam{}, fmt.Errorf(errMissingRequiredParam, qpLimit)
@@ -152,6 +209,10 @@ func parseLimit(qps url.Values) (QueryParam, error) {
 		return QueryParam{}, err
 	}
 
+	if limit <= 0 {
+		return QueryParam{}, fmt.Errorf(errInvalidValue, limit)
+	}
+
 	return QueryParam{
 		Field:  qpLimit,
 		Values: []interface{}{limit},
@@ -384,6 +445,16 @@ func parseBirth(op *string, strValues []string) ([]interface{}, error) {
 	}
 }
 
+// opYear, the param without an operation is the year as well, see implicitOps
+func parseJoined(op *string, strValues []string) ([]interface{}, error) {
+	switch *op {
+	case opYear:
+		return NewParser(strValues).SingleValue().Int().Parse()
+	default:
+		return nil, fmt.Errorf(errInvalidOp, *op)
+	}
+}
+
 // opNow, opNull
 func parsePremium(op *string, strValues []string) ([]interface{}, error) {
 	if op == nil {
@@ -192,19 +192,19 @@ var testGoodQueryParams = []testCaseQueryParam{
 	},
 	{
 		Field:    qpBirth + "_" + opLt,
-		StrValue: strconv.FormatInt(time.Date(1994, 3, 24, 0, 0, 0, 0, time.Local).Unix(), 10),
+		StrValue: strconv.FormatInt(time.Date(1994, 3, 24, 0, 0, 0, 0, time.UTC).Unix(), 10),
 		Expected: QueryParam{
 			Field:  qpBirth,
-			Values: []interface{}{time.Date(1994, 3, 24, 0, 0, 0, 0, time.Local).Unix()},
+			Values: []interface{}{time.Date(1994, 3, 24, 0, 0, 0, 0, time.UTC).Unix()},
 			Op:     util.PtrString(opLt),
 		},
 	},
 	{
 		Field:    qpBirth + "_" + opGt,
-		StrValue: strconv.FormatInt(time.Date(1994, 3, 24, 0, 0, 0, 0, time.Local).Unix(), 10),
+		StrValue: strconv.FormatInt(time.Date(1994, 3, 24, 0, 0, 0, 0, time.UTC).Unix(), 10),
 		Expected: QueryParam{
 			Field:  qpBirth,
-			Values: []interface{}{time.Date(1994, 3, 24, 0, 0, 0, 0, time.Local).Unix()},
+			Values: []interface{}{time.Date(1994, 3, 24, 0, 0, 0, 0, time.UTC).Unix()},
 			Op:     util.PtrString(opGt),
 		},
 	},
@@ -271,3 +271,14 @@ func Test_parseQueryParam_Success(t *testing.T) {
 		assert.Equal(t, tc.Expected, qp)
 	}
 }
+
+// Test_parseQueryParam_ImplicitOp checks the params of the group without an operation.
+func Test_parseQueryParam_ImplicitOp(t *testing.T) {
+	qp, err := parseQueryParam(qpJoined, []string{"2015"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, QueryParam{Field: qpJoined, Values: []interface{}{2015}, Op: util.PtrString(opYear)}, qp)
+
+	qp, err = parseQueryParam(qpBirth, []string{"1994"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, QueryParam{Field: qpBirth, Values: []interface{}{1994}, Op: util.PtrString(opYear)}, qp)
+}
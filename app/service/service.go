@@ -3,44 +3,205 @@ package service
 import (
 	"context"
 	"net/url"
+	"strings"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
 	"accounts/domain"
+	"accounts/util"
 )
 
 const (
 	TimeLayout = "2006-01-02 15:04:05"
 )
 
-type BusinessError struct {
-	error
+// Endpoints the query params are counted by.
+const (
+	endpointFilter    = "filter"
+	endpointGroup     = "group"
+	endpointRecommend = "recommend"
+	endpointSuggest   = "suggest"
+)
+
+// operatorCounter counts the query params the accounts are selected by.
+type operatorCounter interface {
+	CountOperator(endpoint, field, op string)
 }
 
 type AccountService struct {
-	repo accountRepo
+	repo      accountRepo
+	now       func() time.Time
+	logger    *util.Logger
+	operators operatorCounter
 }
 
 func New(repo accountRepo) *AccountService {
 	return &AccountService{
 		repo: repo,
+		now:  utcNow,
 	}
 }
 
+// WithNow fixes the current time premium activity is checked at,
+// the contest supplies it instead of the wall clock.
+func (s *AccountService) WithNow(ts int64) *AccountService {
+	now := time.Unix(ts, 0).UTC()
+	s.now = func() time.Time {
+		return now
+	}
+
+	return s
+}
+
+// WithLogger sets the logger used if the context doesn't carry one.
+func (s *AccountService) WithLogger(l *util.Logger) *AccountService {
+	s.logger = l
+	return s
+}
+
+// WithOperatorCounter counts the fields and the operations of the query params of the valid requests.
+func (s *AccountService) WithOperatorCounter(c operatorCounter) *AccountService {
+	s.operators = c
+	return s
+}
+
 func (s *AccountService) FilterAccounts(ctx context.Context, params url.Values) ([]byte, error) {
 	qps, err := ParseQueryParams(params, true)
 	if err != nil {
-		return nil, BusinessError{err}
+		return nil, domain.NewValidationError(err)
 	}
 
-	filter, err := BuildFilter(qps)
+	filter, err := BuildFilter(qps, s.now())
 	if err != nil {
-		return nil, err
+		return nil, domain.NewValidationError(err)
+	}
+
+	s.countOperators(endpointFilter, qps)
+
+	if logger := util.LoggerFrom(ctx, s.logger); logger.Enabled(util.LevelDebug) {
+		steps := make([]string, 0, len(filter.Steps()))
+		for _, step := range filter.Steps() {
+			steps = append(steps, step.Param)
+		}
+
+		logger.Debug("filter planned", "steps", strings.Join(steps, ","), "limit", filter.Limit)
 	}
 
 	accounts, err := s.repo.FilterAccounts(ctx, filter)
 	if err != nil {
-		return nil, BusinessError{err}
+		return nil, err
+	}
+
+	return jsoniter.Marshal(accounts)
+}
+
+// ExplainFilter describes how the storage evaluates the filter of the params.
+func (s *AccountService) ExplainFilter(ctx context.Context, params url.Values) ([]byte, error) {
+	qps, err := ParseQueryParams(params, true)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	filter, err := BuildFilter(qps, s.now())
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	explain, err := s.repo.ExplainFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(explain), nil
+}
+
+func (s *AccountService) GroupAccounts(ctx context.Context, params url.Values) ([]byte, error) {
+	keys, err := parseGroupKeys(params)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	asc, err := parseGroupOrder(params)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	qps, err := ParseQueryParams(params, false)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	filter, err := BuildFilter(qps, s.now())
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	s.countOperators(endpointGroup, qps)
+
+	groups, err := s.repo.GroupAccounts(ctx, filter, keys, asc)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsoniter.Marshal(groups)
+}
+
+func (s *AccountService) RecommendAccounts(ctx context.Context, id string, params url.Values) ([]byte, error) {
+	accountID, err := parseAccountID(id)
+	if err != nil {
+		return nil, domain.NewNotFoundError(err)
+	}
+
+	qps, err := ParseQueryParams(params, false)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	if err = checkParams(qps, qpCountry, qpCity); err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	filter, err := BuildFilter(qps, s.now())
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	s.countOperators(endpointRecommend, qps)
+
+	accounts, err := s.repo.RecommendAccounts(ctx, accountID, filter, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	return jsoniter.Marshal(accounts)
+}
+
+func (s *AccountService) SuggestAccounts(ctx context.Context, id string, params url.Values) ([]byte, error) {
+	accountID, err := parseAccountID(id)
+	if err != nil {
+		return nil, domain.NewNotFoundError(err)
+	}
+
+	qps, err := ParseQueryParams(params, false)
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	if err = checkParams(qps, qpCountry, qpCity); err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	filter, err := BuildFilter(qps, s.now())
+	if err != nil {
+		return nil, domain.NewValidationError(err)
+	}
+
+	s.countOperators(endpointSuggest, qps)
+
+	accounts, err := s.repo.SuggestAccounts(ctx, accountID, filter)
+	if err != nil {
+		return nil, err
 	}
 
 	return jsoniter.Marshal(accounts)
@@ -49,54 +210,81 @@ func (s *AccountService) FilterAccounts(ctx context.Context, params url.Values)
 func (s *AccountService) AddAccount(ctx context.Context, body []byte) error {
 	var account domain.AccountInput
 	if err := jsoniter.Unmarshal(body, &account); err != nil {
-		return BusinessError{err}
+		return domain.NewValidationError(err)
 	}
 
 	if err := account.Validate(); err != nil {
-		return BusinessError{err}
+		return domain.NewValidationError(err)
 	}
 
 	if err := s.repo.AddAccount(ctx, account); err != nil {
-		return BusinessError{err}
+		return err
 	}
 
 	return nil
 }
 
-func (s *AccountService) UpdateAccount(ctx context.Context, body []byte) error {
+func (s *AccountService) UpdateAccount(ctx context.Context, id string, body []byte) error {
+	accountID, err := parseAccountID(id)
+	if err != nil {
+		return domain.NewNotFoundError(err)
+	}
+
 	var account domain.AccountUpdate
 	if err := jsoniter.Unmarshal(body, &account); err != nil {
-		return BusinessError{err}
+		return domain.NewValidationError(err)
 	}
 
+	account.ID = domain.FieldID(accountID)
 	if err := account.Validate(); err != nil {
-		return BusinessError{err}
+		return domain.NewValidationError(err)
 	}
 
 	if err := s.repo.UpdateAccount(ctx, account); err != nil {
-		return BusinessError{err}
+		return err
 	}
 
 	return nil
 }
 
 func (s *AccountService) AddLikes(ctx context.Context, body []byte) error {
-	var likes []domain.LikeInput
-	if err := jsoniter.Unmarshal(body, &likes); err != nil {
-		return BusinessError{err}
-	}
-
-	input := &domain.LikesInput{
-		Likes: likes,
+	input := &domain.LikesInput{}
+	if err := jsoniter.Unmarshal(body, input); err != nil {
+		return domain.NewValidationError(err)
 	}
 
 	if err := input.Validate(); err != nil {
-		return BusinessError{err}
+		return domain.NewValidationError(err)
 	}
 
 	if err := s.repo.AddLikes(ctx, input); err != nil {
-		return BusinessError{err}
+		return err
 	}
 
 	return nil
 }
+
+// countOperators counts the params except limit, those without an operation are equalities.
+func (s *AccountService) countOperators(endpoint string, qps map[string]QueryParam) {
+	if s.operators == nil {
+		return
+	}
+
+	for _, qp := range qps {
+		if qp.Field == qpLimit {
+			continue
+		}
+
+		op := opEq
+		if qp.Op != nil {
+			op = *qp.Op
+		}
+
+		s.operators.CountOperator(endpoint, qp.Field, op)
+	}
+}
+
+// utcNow is the wall clock in UTC, the timestamps are stored in UTC.
+func utcNow() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app/memory"
+	"accounts/app/repository"
+	"accounts/domain"
+	"accounts/migrations"
+)
+
+// testStorages returns the storages the shared tests run against: the memory one
+// and Postgres with the recreated schema if TEST_DB_CONN is set.
+func testStorages(t *testing.T) map[string]accountRepo {
+	storages := map[string]accountRepo{
+		"memory": memory.New(),
+	}
+
+	connStr := os.Getenv("TEST_DB_CONN")
+	if connStr == "" {
+		return storages
+	}
+
+	ctx := context.Background()
+	conn, err := pgxpool.Connect(ctx, connStr)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	require.NoError(t, migrations.Down(ctx, conn, true))
+	require.NoError(t, migrations.ApplyDeferred(ctx, conn))
+
+	storages["postgres"] = repository.New(conn)
+	return storages
+}
+
+func unix(year int, month time.Month, day int) int64 {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix()
+}
+
+var (
+	testNow = unix(2019, time.January, 1)
+
+	testAccounts = []map[string]interface{}{
+		{
+			"id": 1, "email": "a1@mail.ru", "sex": "m", "fname": "Иван", "sname": "Петров",
+			"phone": "8(900)1000001", "country": "Россия", "city": "Москва",
+			"birth": unix(1990, time.May, 1), "joined": unix(2012, time.January, 1), "status": "свободны",
+			"interests": []string{"музыка", "кино"},
+			"premium":   map[string]int64{"start": unix(2018, time.June, 1), "finish": unix(2019, time.June, 1)},
+		},
+		{
+			"id": 2, "email": "b2@gmail.com", "sex": "f", "fname": "Анна", "sname": "Петрова",
+			"phone": "8(901)1000002", "country": "Россия", "city": "Москва",
+			"birth": unix(1992, time.March, 1), "joined": unix(2013, time.January, 1), "status": "заняты",
+			"interests": []string{"музыка", "спорт"},
+		},
+		{
+			"id": 3, "email": "c3@mail.ru", "sex": "f", "fname": "Мария", "country": "Испания",
+			"birth": unix(1990, time.July, 1), "joined": unix(2014, time.January, 1), "status": "всё сложно",
+			"interests": []string{"кино"},
+			"premium":   map[string]int64{"start": unix(2018, time.January, 1), "finish": unix(2018, time.June, 1)},
+		},
+		{
+			"id": 4, "email": "d4@yandex.ru", "sex": "m", "fname": "Олег", "sname": "Сидоров",
+			"phone": "8(900)1000004",
+			"birth": unix(1985, time.January, 1), "joined": unix(2015, time.January, 1), "status": "свободны",
+			"interests": []string{"спорт", "кино", "музыка"},
+		},
+		{
+			"id": 5, "email": "e5@mail.ru", "sex": "f", "sname": "Сидорова",
+			"phone": "8(902)1000005", "country": "Россия", "city": "Казань",
+			"birth": unix(1995, time.January, 1), "joined": unix(2016, time.January, 1), "status": "свободны",
+			"interests": []string{"музыка", "кино"},
+			"premium":   map[string]int64{"start": unix(2018, time.December, 1), "finish": unix(2019, time.December, 1)},
+		},
+		{
+			"id": 6, "email": "f6@gmail.com", "sex": "m", "fname": "Иван", "sname": "Иванов",
+			"country": "Испания", "city": "Мадрид",
+			"birth": unix(1988, time.January, 1), "joined": unix(2017, time.January, 1), "status": "заняты",
+		},
+	}
+
+	// likes of 1 are similar to the likes of 4 (repeated likes are averaged) more than to the ones of 6
+	testLikes = []map[string]int64{
+		{"liker": 1, "likee": 2, "ts": 1500000000},
+		{"liker": 1, "likee": 3, "ts": 1500000100},
+		{"liker": 4, "likee": 2, "ts": 1500000000},
+		{"liker": 4, "likee": 2, "ts": 1500000020},
+		{"liker": 4, "likee": 3, "ts": 1500000000},
+		{"liker": 4, "likee": 5, "ts": 1500000500},
+		{"liker": 6, "likee": 2, "ts": 1500001000},
+		{"liker": 6, "likee": 4, "ts": 1500000000},
+	}
+)
+
+func testService(t *testing.T, storage accountRepo) *AccountService {
+	s := New(storage).WithNow(testNow)
+	ctx := context.Background()
+	for _, account := range testAccounts {
+		body, err := json.Marshal(account)
+		require.NoError(t, err)
+		require.NoError(t, s.AddAccount(ctx, body))
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"likes": testLikes})
+	require.NoError(t, err)
+	require.NoError(t, s.AddLikes(ctx, body))
+
+	return s
+}
+
+func query(t *testing.T, s string) url.Values {
+	values, err := url.ParseQuery(s)
+	require.NoError(t, err)
+
+	return values
+}
+
+func accountIDs(t *testing.T, body []byte) []int32 {
+	var out domain.AccountsOut
+	require.NoError(t, json.Unmarshal(body, &out))
+
+	ids := make([]int32, 0, len(out.Accounts))
+	for _, a := range out.Accounts {
+		ids = append(ids, a.ID)
+	}
+
+	return ids
+}
+
+func Test_Storages_FilterAccounts(t *testing.T) {
+	testcases := []struct {
+		query    string
+		expected []int32
+	}{
+		{query: "sex_eq=f&limit=10", expected: []int32{5, 3, 2}},
+		{query: "status_neq=свободны&limit=10", expected: []int32{6, 3, 2}},
+		{query: "email_domain=mail.ru&limit=10", expected: []int32{5, 3, 1}},
+		{query: "email_lt=c&limit=10", expected: []int32{2, 1}},
+		{query: "email_gt=d&limit=10", expected: []int32{6, 5, 4}},
+		{query: "fname_any=Иван,Анна&limit=10", expected: []int32{6, 2, 1}},
+		{query: "fname_null=1&limit=10", expected: []int32{5}},
+		{query: "fname_null=0&limit=2", expected: []int32{6, 4}},
+		{query: "sname_starts=Сидор&limit=10", expected: []int32{5, 4}},
+		{query: "sname_null=1&limit=10", expected: []int32{3}},
+		{query: "phone_code=900&limit=10", expected: []int32{4, 1}},
+		{query: "phone_null=1&limit=10", expected: []int32{6, 3}},
+		{query: "country_eq=Испания&limit=10", expected: []int32{6, 3}},
+		{query: "country_null=1&limit=10", expected: []int32{4}},
+		{query: "city_any=Москва,Казань&limit=10", expected: []int32{5, 2, 1}},
+		{query: "city_null=0&limit=10", expected: []int32{6, 5, 2, 1}},
+		{query: "birth_year=1990&limit=10", expected: []int32{3, 1}},
+		{query: fmt.Sprintf("birth_lt=%d&limit=10", unix(1989, time.January, 1)), expected: []int32{6, 4}},
+		{query: fmt.Sprintf("birth_gt=%d&limit=10", unix(1991, time.January, 1)), expected: []int32{5, 2}},
+		{query: "interests_contains=музыка,кино&limit=10", expected: []int32{5, 4, 1}},
+		{query: "interests_any=спорт&limit=10", expected: []int32{4, 2}},
+		{query: "likes_contains=2,3&limit=10", expected: []int32{4, 1}},
+		{query: "premium_now=1&limit=10", expected: []int32{5, 1}},
+		{query: "premium_null=0&limit=10", expected: []int32{5, 3, 1}},
+		{query: "sex_eq=m&status_eq=свободны&limit=1", expected: []int32{4}},
+		{query: "city_eq=Париж&limit=10", expected: []int32{}},
+	}
+
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := testService(t, storage)
+			for _, tc := range testcases {
+				body, err := s.FilterAccounts(context.Background(), query(t, "query_id=1&"+tc.query))
+				require.NoError(t, err, tc.query)
+				assert.Equal(t, tc.expected, accountIDs(t, body), tc.query)
+			}
+
+			body, err := s.FilterAccounts(context.Background(), query(t, "query_id=1&sex_eq=f&city_null=0&premium_now=1&limit=10"))
+			require.NoError(t, err)
+			assert.JSONEq(t, fmt.Sprintf(
+				`{"accounts":[{"id":5,"email":"e5@mail.ru","sex":"f","city":"Казань","premium":{"start":%d,"finish":%d}}]}`,
+				unix(2018, time.December, 1), unix(2019, time.December, 1)), string(body))
+		})
+	}
+}
+
+func Test_Storages_GroupAccounts(t *testing.T) {
+	testcases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "keys=sex&order=1&limit=10",
+			expected: `[{"sex":"f","count":3},{"sex":"m","count":3}]`,
+		},
+		{
+			query:    "keys=country&order=-1&limit=10",
+			expected: `[{"country":"Россия","count":3},{"country":"Испания","count":2},{"count":1}]`,
+		},
+		{
+			query:    "keys=city&order=1&limit=10",
+			expected: `[{"city":"Казань","count":1},{"city":"Мадрид","count":1},{"city":"Москва","count":2},{"count":2}]`,
+		},
+		{
+			query:    "keys=interests&order=-1&limit=3",
+			expected: `[{"interests":"музыка","count":4},{"interests":"кино","count":4},{"interests":"спорт","count":2}]`,
+		},
+		{
+			query:    "keys=sex,status&sex=m&order=-1&limit=10",
+			expected: `[{"sex":"m","status":"свободны","count":2},{"sex":"m","status":"заняты","count":1}]`,
+		},
+		{
+			query:    "keys=city&interests=кино&order=-1&limit=10",
+			expected: `[{"count":2},{"city":"Москва","count":1},{"city":"Казань","count":1}]`,
+		},
+		{
+			query:    "keys=country&birth=1990&order=1&limit=10",
+			expected: `[{"country":"Испания","count":1},{"country":"Россия","count":1}]`,
+		},
+		{
+			query:    "keys=sex&likes=2&order=1&limit=10",
+			expected: `[{"sex":"m","count":3}]`,
+		},
+	}
+
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := testService(t, storage)
+			for _, tc := range testcases {
+				body, err := s.GroupAccounts(context.Background(), query(t, "query_id=1&"+tc.query))
+				require.NoError(t, err, tc.query)
+				assert.JSONEq(t, `{"groups":`+tc.expected+`}`, string(body), tc.query)
+			}
+		})
+	}
+}
+
+func Test_Storages_RecommendSuggest(t *testing.T) {
+	testcases := []struct {
+		id       string
+		query    string
+		suggest  bool
+		expected []int32
+	}{
+		{id: "1", query: "limit=10", expected: []int32{5, 3, 2}},
+		{id: "1", query: "country=Россия&limit=10", expected: []int32{5, 2}},
+		{id: "1", query: "city=Москва&limit=10", expected: []int32{2}},
+		{id: "1", query: "limit=2", expected: []int32{5, 3}},
+		{id: "2", query: "limit=10", expected: []int32{1, 4}},
+		{id: "6", query: "limit=10", expected: []int32{}},
+		{id: "1", query: "limit=10", suggest: true, expected: []int32{5, 4}},
+		{id: "1", query: "country=Испания&limit=10", suggest: true, expected: []int32{4}},
+		{id: "1", query: "limit=1", suggest: true, expected: []int32{5}},
+		{id: "5", query: "limit=10", suggest: true, expected: []int32{}},
+	}
+
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := testService(t, storage)
+			ctx := context.Background()
+			for _, tc := range testcases {
+				fn := s.RecommendAccounts
+				if tc.suggest {
+					fn = s.SuggestAccounts
+				}
+
+				body, err := fn(ctx, tc.id, query(t, "query_id=1&"+tc.query))
+				require.NoError(t, err, tc.id, tc.query)
+				assert.Equal(t, tc.expected, accountIDs(t, body), tc.id, tc.query)
+			}
+
+			body, err := s.RecommendAccounts(ctx, "2", query(t, "query_id=1&limit=1"))
+			require.NoError(t, err)
+			assert.JSONEq(t, fmt.Sprintf(
+				`{"accounts":[{"id":1,"email":"a1@mail.ru","status":"свободны","fname":"Иван","sname":"Петров","birth":%d,"premium":{"start":%d,"finish":%d}}]}`,
+				unix(1990, time.May, 1), unix(2018, time.June, 1), unix(2019, time.June, 1)), string(body))
+
+			_, err = s.RecommendAccounts(ctx, "100", query(t, "query_id=1&limit=10"))
+			assert.Equal(t, domain.ErrorNotFound, domain.KindOf(err))
+
+			_, err = s.SuggestAccounts(ctx, "100", query(t, "query_id=1&limit=10"))
+			assert.Equal(t, domain.ErrorNotFound, domain.KindOf(err))
+		})
+	}
+}
+
+func Test_Storages_Updates(t *testing.T) {
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := testService(t, storage)
+			ctx := context.Background()
+
+			filter := func(q string) []int32 {
+				body, err := s.FilterAccounts(ctx, query(t, "query_id=1&"+q))
+				require.NoError(t, err, q)
+				return accountIDs(t, body)
+			}
+
+			err := s.AddAccount(ctx, []byte(fmt.Sprintf(
+				`{"id":7,"email":"a1@mail.ru","sex":"m","birth":%d,"joined":%d,"status":"заняты"}`,
+				unix(1990, time.January, 1), unix(2015, time.January, 1))))
+			assert.Equal(t, domain.ErrorConflict, domain.KindOf(err))
+
+			err = s.AddAccount(ctx, []byte(fmt.Sprintf(
+				`{"id":7,"email":"g7@mail.ru","sex":"m","birth":%d,"joined":%d,"status":"заняты","likes":[{"id":100,"ts":1500000000}]}`,
+				unix(1990, time.January, 1), unix(2015, time.January, 1))))
+			assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+
+			err = s.UpdateAccount(ctx, "100", []byte(`{"fname":"Пётр"}`))
+			assert.Equal(t, domain.ErrorNotFound, domain.KindOf(err))
+
+			err = s.UpdateAccount(ctx, "100", []byte(`{"email":"a1@mail.ru"}`))
+			assert.Equal(t, domain.ErrorNotFound, domain.KindOf(err))
+
+			err = s.UpdateAccount(ctx, "6", []byte(`{"phone":"8(900)1000001"}`))
+			assert.Equal(t, domain.ErrorConflict, domain.KindOf(err))
+
+			err = s.AddLikes(ctx, []byte(`{"likes":[{"liker":3,"likee":100,"ts":1500000000}]}`))
+			assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+
+			err = s.UpdateAccount(ctx, "6", []byte(`{"likes":[{"id":100,"ts":1500000000}]}`))
+			assert.Equal(t, domain.ErrorValidation, domain.KindOf(err))
+
+			require.NoError(t, s.UpdateAccount(ctx, "6", []byte(
+				`{"email":"f6@mail.ru","city":"Казань","interests":["музыка"],"likes":[{"id":5,"ts":1500000000}]}`)))
+			assert.Equal(t, []int32{6, 5}, filter("city_eq=Казань&limit=10"))
+			assert.Equal(t, []int32{6, 5, 3, 1}, filter("email_domain=mail.ru&limit=10"))
+			assert.Equal(t, []int32{6, 5, 4, 2, 1}, filter("interests_any=музыка&limit=10"))
+			assert.Equal(t, []int32{6, 4}, filter("likes_contains=5&limit=10"))
+
+			require.NoError(t, s.UpdateAccount(ctx, "6", []byte(`{"interests":[]}`)))
+			assert.Equal(t, []int32{5, 4, 2, 1}, filter("interests_any=музыка&limit=10"))
+
+			require.NoError(t, s.AddLikes(ctx, []byte(`{"likes":[{"liker":3,"likee":6,"ts":1500000000}]}`)))
+			assert.Equal(t, []int32{3}, filter("likes_contains=6&limit=10"))
+		})
+	}
+}
+
+// Test_Storages_Collation checks that the storages compare the strings byte by byte whatever
+// the collation of the database: the upper case goes before the lower one and Ё before А.
+func Test_Storages_Collation(t *testing.T) {
+	accounts := []string{
+		`{"id":7,"email":"Zed7@mail.ru","sex":"m","city":"berlin","country":"Österreich","status":"заняты"}`,
+		`{"id":8,"email":"zed8@mail.ru","sex":"m","city":"Berlin","country":"Ёлкия","status":"заняты"}`,
+		`{"id":9,"email":"Éva9@mail.ru","sex":"m","city":"Ёлкино","country":"austria","status":"заняты"}`,
+	}
+
+	for name, storage := range testStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			s := testService(t, storage)
+			ctx := context.Background()
+			for _, account := range accounts {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(account), &body))
+				body["birth"], body["joined"] = unix(1990, time.January, 1), unix(2015, time.January, 1)
+
+				raw, err := json.Marshal(body)
+				require.NoError(t, err)
+				require.NoError(t, s.AddAccount(ctx, raw))
+			}
+
+			for q, expected := range map[string][]int32{
+				"email_lt=a&limit=10": {7},
+				"email_gt=z&limit=10": {9, 8},
+			} {
+				body, err := s.FilterAccounts(ctx, query(t, "query_id=1&"+q))
+				require.NoError(t, err, q)
+				assert.Equal(t, expected, accountIDs(t, body), q)
+			}
+
+			for q, expected := range map[string]string{
+				"keys=city&status=заняты&order=1&limit=10": `[{"city":"Berlin","count":1},{"city":"berlin","count":1},` +
+					`{"city":"Ёлкино","count":1},{"city":"Мадрид","count":1},{"city":"Москва","count":1}]`,
+				"keys=country&status=заняты&order=-1&limit=10": `[{"country":"Россия","count":1},{"country":"Испания","count":1},` +
+					`{"country":"Ёлкия","count":1},{"country":"Österreich","count":1},{"country":"austria","count":1}]`,
+			} {
+				body, err := s.GroupAccounts(ctx, query(t, "query_id=1&"+q))
+				require.NoError(t, err, q)
+				assert.JSONEq(t, `{"groups":`+expected+`}`, string(body), q)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"accounts/domain"
+	"accounts/util"
+)
+
+// snapshotBatchSize is the number of accounts passed to the storage at once.
+const snapshotBatchSize = 10000
+
+var snapshotEntry = regexp.MustCompile(`^accounts_(\d+)\.json$`)
+
+// ErrSnapshotSkipped is returned by LoadSnapshot if the storage already has accounts,
+// e.g. postgres loaded on the previous start.
+var ErrSnapshotSkipped = errors.New("the storage already has accounts")
+
+// snapshotLoader is a storage the snapshot accounts are loaded into.
+type snapshotLoader interface {
+	HasAccounts(ctx context.Context) (bool, error)
+	LoadAccounts(ctx context.Context, accounts []domain.AccountInput) error
+}
+
+// LoadSnapshot loads the accounts of the accounts_N.json entries of data.zip in the order of N,
+// each entry is streamed out of the archive and decoded account by account.
+// It returns the number of the loaded accounts. Nothing is loaded into a storage having accounts,
+// as the snapshot would collide with them, ErrSnapshotSkipped is returned instead.
+func LoadSnapshot(ctx context.Context, path string, loader snapshotLoader) (int, error) {
+	hasAccounts, err := loader.HasAccounts(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if hasAccounts {
+		return 0, ErrSnapshotSkipped
+	}
+
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer archive.Close()
+
+	loaded := 0
+	for _, entry := range snapshotEntries(archive.File) {
+		count, err := loadSnapshotEntry(ctx, entry, loader)
+		loaded += count
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+	}
+
+	return loaded, nil
+}
+
+// snapshotEntries returns the accounts_N.json entries sorted by N.
+func snapshotEntries(files []*zip.File) []*zip.File {
+	entries := make([]*zip.File, 0, len(files))
+	numbers := make(map[*zip.File]int, len(files))
+	for _, file := range files {
+		match := snapshotEntry.FindStringSubmatch(file.Name)
+		if match == nil {
+			continue
+		}
+
+		numbers[file], _ = strconv.Atoi(match[1])
+		entries = append(entries, file)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return numbers[entries[i]] < numbers[entries[j]]
+	})
+
+	return entries
+}
+
+func loadSnapshotEntry(ctx context.Context, entry *zip.File, loader snapshotLoader) (int, error) {
+	r, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+
+	loaded := 0
+	batch := make([]domain.AccountInput, 0, snapshotBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := loader.LoadAccounts(ctx, batch); err != nil {
+			return err
+		}
+
+		loaded += len(batch)
+		batch = make([]domain.AccountInput, 0, snapshotBatchSize)
+		return nil
+	}
+
+	err = util.DecodeArray(r, "accounts", func(dec *json.Decoder) error {
+		var account domain.AccountInput
+		if err := dec.Decode(&account); err != nil {
+			return err
+		}
+
+		if err := account.Validate(); err != nil {
+			return fmt.Errorf("invalid account: %w", err)
+		}
+
+		batch = append(batch, account)
+		if len(batch) < snapshotBatchSize {
+			return nil
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return loaded, err
+	}
+
+	return loaded, flush()
+}
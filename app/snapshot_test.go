@@ -0,0 +1,107 @@
+package app
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app/memory"
+	"accounts/app/repository"
+)
+
+func writeSnapshot(t *testing.T, entries map[string]string) string {
+	path := filepath.Join(t.TempDir(), "data.zip")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+
+	archive := zip.NewWriter(file)
+	for name, content := range entries {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, archive.Close())
+	require.NoError(t, file.Close())
+
+	return path
+}
+
+func snapshotAccount(id int, likee int) string {
+	return fmt.Sprintf(`{"id": %d, "email": "%d@test.ru", "sex": "m", "birth": 631152000, "joined": 1420070400,
+		"status": "свободны", "city": "Москва", "interests": ["Футбол"], "likes": [{"id": %d, "ts": 1500000000}]}`, id, id, likee)
+}
+
+func Test_LoadSnapshot(t *testing.T) {
+	path := writeSnapshot(t, map[string]string{
+		"accounts_10.json": fmt.Sprintf(`{"accounts": [%s]}`, snapshotAccount(3, 1)),
+		"accounts_2.json":  fmt.Sprintf(`{"accounts": [%s, %s]}`, snapshotAccount(1, 3), snapshotAccount(2, 3)),
+		"options.txt":      "1500000000\n1\n",
+	})
+
+	storage := memory.New()
+	loaded, err := LoadSnapshot(context.Background(), path, storage)
+	require.NoError(t, err)
+	assert.Equal(t, 3, loaded)
+
+	f := repository.NewFilter()
+	f.Contains(repository.LikesLikeeID, []interface{}{"3"})
+	accounts, err := storage.FilterAccounts(context.Background(), f)
+	require.NoError(t, err)
+
+	ids := make([]int32, 0)
+	for _, a := range accounts.Accounts {
+		ids = append(ids, a.ID)
+	}
+
+	assert.Equal(t, []int32{2, 1}, ids)
+}
+
+// Test_LoadSnapshot_Restart checks that the snapshot isn't loaded again into a storage having accounts,
+// as postgres has them on a restart of the server.
+func Test_LoadSnapshot_Restart(t *testing.T) {
+	path := writeSnapshot(t, map[string]string{
+		"accounts_1.json": fmt.Sprintf(`{"accounts": [%s, %s]}`, snapshotAccount(1, 2), snapshotAccount(2, 1)),
+	})
+
+	storage := memory.New()
+	loaded, err := LoadSnapshot(context.Background(), path, storage)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loaded)
+
+	loaded, err = LoadSnapshot(context.Background(), path, storage)
+	assert.ErrorIs(t, err, ErrSnapshotSkipped)
+	assert.Zero(t, loaded)
+
+	stats, err := storage.DatasetStats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Accounts)
+	assert.Equal(t, int64(2), stats.Likes)
+}
+
+func Test_LoadSnapshot_InvalidAccount(t *testing.T) {
+	path := writeSnapshot(t, map[string]string{
+		"accounts_1.json": `{"accounts": [{"id": 1, "email": "invalid"}]}`,
+	})
+
+	_, err := LoadSnapshot(context.Background(), path, memory.New())
+	assert.Error(t, err)
+}
+
+func Test_ReadOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "options.txt")
+	require.NoError(t, ioutil.WriteFile(path, []byte("1545834028\n1\n"), 0644))
+
+	options, err := ReadOptions(path)
+	require.NoError(t, err)
+	assert.Equal(t, &Options{Now: 1545834028, Mode: ModeRating}, options)
+}
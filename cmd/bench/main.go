@@ -0,0 +1,13 @@
+package main
+
+import (
+	"log"
+
+	"accounts/tools/bench"
+)
+
+func main() {
+	if err := bench.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"log"
+
+	"accounts/tools/replay"
+)
+
+func main() {
+	if err := replay.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -6,11 +6,11 @@ import (
 )
 
 const (
-	statusFree        = "свободны"
-	statusBusy        = "заняты"
-	statusComplicated = "всё сложно"
-	sexMale           = "m"
-	sexFemale         = "f"
+	StatusFree        = "свободны"
+	StatusBusy        = "заняты"
+	StatusComplicated = "всё сложно"
+	SexMale           = "m"
+	SexFemale         = "f"
 )
 
 const (
@@ -24,11 +24,11 @@ const (
 )
 
 var (
-	minBirth   = time.Date(1950, 1, 1, 0, 0, 0, 0, time.Local).Unix()
-	maxBirth   = time.Date(2005, 1, 1, 0, 0, 0, 0, time.Local).Unix()
-	minJoined  = time.Date(2011, 1, 1, 0, 0, 0, 0, time.Local).Unix()
-	maxJoined  = time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local).Unix()
-	minPremium = time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local).Unix()
+	minBirth   = time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	maxBirth   = time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	minJoined  = time.Date(2011, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	maxJoined  = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	minPremium = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
 )
 
 var (
@@ -36,9 +36,9 @@ var (
 )
 
 func statusIsValid(s string) bool {
-	return s == statusBusy || s == statusComplicated || s == statusFree
+	return s == StatusBusy || s == StatusComplicated || s == StatusFree
 }
 
 func sexIsValid(s string) bool {
-	return s == sexFemale || s == sexMale
+	return s == SexFemale || s == SexMale
 }
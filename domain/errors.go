@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+)
+
+type ErrorKind int
+
+const (
+	// ErrorInternal is the kind of unexpected errors, e.g. database failures
+	ErrorInternal ErrorKind = iota
+	// ErrorValidation is the kind of malformed or invalid input
+	ErrorValidation
+	// ErrorNotFound is the kind of requests to entities which don't exist
+	ErrorNotFound
+	// ErrorConflict is the kind of input violating uniqueness, e.g. a duplicate email
+	ErrorConflict
+)
+
+// Error is an error of a known kind, the transport layer maps kinds to responses.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewValidationError(err error) error {
+	return newError(ErrorValidation, err)
+}
+
+func NewNotFoundError(err error) error {
+	return newError(ErrorNotFound, err)
+}
+
+func NewConflictError(err error) error {
+	return newError(ErrorConflict, err)
+}
+
+func NewInternalError(err error) error {
+	return newError(ErrorInternal, err)
+}
+
+// KindOf returns the kind of the error, errors of unknown kinds are internal.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return ErrorInternal
+}
+
+// newError keeps the kind of the error if it's already known.
+func newError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{Kind: kind, Err: err}
+}
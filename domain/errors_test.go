@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KindOf(t *testing.T) {
+	raw := errors.New("raw")
+
+	assert.Equal(t, ErrorInternal, KindOf(raw))
+	assert.Equal(t, ErrorValidation, KindOf(NewValidationError(raw)))
+	assert.Equal(t, ErrorNotFound, KindOf(NewNotFoundError(raw)))
+	assert.Equal(t, ErrorConflict, KindOf(NewConflictError(raw)))
+	assert.Equal(t, ErrorInternal, KindOf(NewInternalError(raw)))
+
+	// the kind survives wrapping and isn't overwritten
+	wrapped := fmt.Errorf("wrapped: %w", NewNotFoundError(raw))
+	assert.Equal(t, ErrorNotFound, KindOf(wrapped))
+	assert.Equal(t, ErrorNotFound, KindOf(NewValidationError(wrapped)))
+	assert.True(t, errors.Is(NewConflictError(raw), raw))
+
+	assert.Nil(t, NewValidationError(nil))
+}
@@ -8,7 +8,7 @@ import (
 )
 
 var (
-	errInvalidValue = errors.New("invalid value")
+	errInvalidValue = NewValidationError(errors.New("invalid value"))
 )
 
 type FieldID int32
@@ -9,7 +9,7 @@ import (
 )
 
 var (
-	errEmptyField = errors.New("empty field")
+	errEmptyField = NewValidationError(errors.New("empty field"))
 )
 
 type AccountInput struct {
@@ -187,11 +187,19 @@ func (a *AccountLikeInput) Validate() error {
 type AccountUpdate struct {
 	ID FieldID `json:"-"`
 
-	Email   *FieldEmail   `json:"email,omitempty"`
-	Birth   *FieldBirth   `json:"birth,omitempty"`
-	City    *FieldCity    `json:"city,omitempty"`
-	Country *FieldCountry `json:"country,omitempty"`
-	Status  *FieldStatus  `json:"status,omitempty"`
+	Email     *FieldEmail         `json:"email,omitempty"`
+	Sex       *FieldSex           `json:"sex,omitempty"`
+	Birth     *FieldBirth         `json:"birth,omitempty"`
+	Joined    *FieldJoined        `json:"joined,omitempty"`
+	Status    *FieldStatus        `json:"status,omitempty"`
+	Name      *FieldFirstname     `json:"fname,omitempty"`
+	Surname   *FieldSurname       `json:"sname,omitempty"`
+	Phone     *FieldPhone         `json:"phone,omitempty"`
+	City      *FieldCity          `json:"city,omitempty"`
+	Country   *FieldCountry       `json:"country,omitempty"`
+	Interests []*FieldInterest    `json:"interests,omitempty"`
+	Premium   *PremiumInput       `json:"premium,omitempty"`
+	Likes     []*AccountLikeInput `json:"likes,omitempty"`
 
 	validated bool
 }
@@ -201,7 +209,64 @@ func (a *AccountUpdate) Validate() error {
 		a.validated = true
 	}()
 
-	return checkValidators(&a.ID, a.Email, a.Birth, a.City, a.Country, a.Status)
+	for _, interest := range a.Interests {
+		if interest == nil {
+			return errEmptyField
+		}
+		if interest.Validate() != nil {
+			return errInvalidValue
+		}
+	}
+
+	for _, like := range a.Likes {
+		if like == nil {
+			return errEmptyField
+		}
+		if like.Validate() != nil {
+			return errInvalidValue
+		}
+	}
+
+	return checkValidators(
+		&a.ID, a.Email, a.Sex, a.Birth, a.Joined, a.Status, a.Name,
+		a.Surname, a.Phone, a.City, a.Country, a.Premium,
+	)
+}
+
+// InterestModels returns nil if interests aren't updated,
+// otherwise the models replacing the current interests (possibly none).
+func (a *AccountUpdate) InterestModels() []InterestModel {
+	if a.Interests == nil || !a.validated {
+		return nil
+	}
+
+	models := make([]InterestModel, 0, len(a.Interests))
+	for _, interest := range a.Interests {
+		models = append(models, InterestModel{
+			AccountID: int32(a.ID),
+			Name:      string(*interest),
+		})
+	}
+
+	return models
+}
+
+// LikeModels returns the likes appended to the current ones.
+func (a *AccountUpdate) LikeModels() []LikeModel {
+	if len(a.Likes) == 0 || !a.validated {
+		return nil
+	}
+
+	models := make([]LikeModel, 0, len(a.Likes))
+	for _, like := range a.Likes {
+		models = append(models, LikeModel{
+			LikerID:   int32(a.ID),
+			LikeeID:   int32(*like.UserID),
+			Timestamp: *util.TimestampToDatetime((*int64)(like.Timestamp)),
+		})
+	}
+
+	return models
 }
 
 func (a *AccountUpdate) AccountModel(cityID, countryID *uuid.UUID) *AccountModel {
@@ -225,6 +290,7 @@ func (a *AccountUpdate) CityModel() *CityModel {
 	}
 
 	return &CityModel{
+		ID:   uuid.New(),
 		Name: string(*a.City),
 	}
 }
@@ -235,6 +301,7 @@ func (a *AccountUpdate) CountryModel() *CountryModel {
 	}
 
 	return &CountryModel{
+		ID:   uuid.New(),
 		Name: string(*a.Country),
 	}
 }
@@ -275,6 +342,28 @@ func (li *LikesInput) LikeModels() []LikeModel {
 	return likeModels
 }
 
+// AccountIDs returns the distinct ids of likers and likees.
+func (li *LikesInput) AccountIDs() []int32 {
+	seen := make(map[int32]struct{}, len(li.Likes))
+	ids := make([]int32, 0, len(li.Likes))
+	for _, like := range li.Likes {
+		for _, id := range []*FieldID{like.Liker, like.Likee} {
+			if id == nil {
+				continue
+			}
+
+			if _, ok := seen[int32(*id)]; ok {
+				continue
+			}
+
+			seen[int32(*id)] = struct{}{}
+			ids = append(ids, int32(*id))
+		}
+	}
+
+	return ids
+}
+
 type LikeInput struct {
 	Likee     *FieldID        `json:"likee"`
 	Liker     *FieldID        `json:"liker"`
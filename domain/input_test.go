@@ -21,8 +21,8 @@ type testcaseAccount struct {
 }
 
 var (
-	testNow              = time.Date(2018, 1, 1, 0, 0, 0, 0, time.Local)
-	testBirth            = time.Date(1994, 1, 1, 0, 0, 0, 0, time.Local)
+	testNow              = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	testBirth            = time.Date(1994, 1, 1, 0, 0, 0, 0, time.UTC)
 	goodAccountTestcases = []testcaseAccount{
 		{
 			Input: AccountInput{
@@ -144,3 +144,44 @@ func Test_AccountInputToModels_Success(t *testing.T) {
 		}
 	}
 }
+
+func Test_AccountUpdateToModels_Success(t *testing.T) {
+	update := AccountUpdate{
+		ID: 5,
+		Interests: []*FieldInterest{
+			(*FieldInterest)(util.PtrString("кино")),
+		},
+		Likes: []*AccountLikeInput{
+			{
+				UserID:    (*FieldID)(util.PtrInt32(2)),
+				Timestamp: (*FieldTimestamp)(util.PtrInt64(testNow.Unix())),
+			},
+		},
+	}
+
+	if err := update.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []InterestModel{{AccountID: 5, Name: "кино"}}, update.InterestModels())
+	assert.Equal(t, []LikeModel{{LikerID: 5, LikeeID: 2, Timestamp: testNow}}, update.LikeModels())
+
+	// an empty list replaces the interests with none, a missing one keeps them
+	update.Interests = []*FieldInterest{}
+	assert.Equal(t, []InterestModel{}, update.InterestModels())
+	update.Interests = nil
+	assert.Nil(t, update.InterestModels())
+}
+
+func Test_LikesInput_AccountIDs(t *testing.T) {
+	like := func(liker, likee int32) LikeInput {
+		return LikeInput{
+			Liker:     (*FieldID)(util.PtrInt32(liker)),
+			Likee:     (*FieldID)(util.PtrInt32(likee)),
+			Timestamp: (*FieldTimestamp)(util.PtrInt64(testNow.Unix())),
+		}
+	}
+
+	input := LikesInput{Likes: []LikeInput{like(1, 2), like(2, 3), like(1, 3)}}
+	assert.Equal(t, []int32{1, 2, 3}, input.AccountIDs())
+}
@@ -5,15 +5,33 @@ type AccountsOut struct {
 }
 
 type AccountOut struct {
-	ID      int32   `json:"id"`
-	Email   string  `json:"email"`
-	Sex     string  `json:"sex,omitempty"`
-	Status  string  `json:"status,omitempty"`
-	Birth   int64   `json:"birth,omitempty"`
-	Fname   *string `json:"fname,omitempty"`
-	Sname   *string `json:"sname,omitempty"`
-	Phone   *string `json:"phone,omitempty"`
-	Country *string `json:"country,omitempty"`
-	City    *string `json:"city,omitempty"`
-	Premium *int64  `json:"premium,omitempty"` // ?
+	ID      int32       `json:"id"`
+	Email   string      `json:"email"`
+	Sex     string      `json:"sex,omitempty"`
+	Status  string      `json:"status,omitempty"`
+	Birth   int64       `json:"birth,omitempty"`
+	Fname   *string     `json:"fname,omitempty"`
+	Sname   *string     `json:"sname,omitempty"`
+	Phone   *string     `json:"phone,omitempty"`
+	Country *string     `json:"country,omitempty"`
+	City    *string     `json:"city,omitempty"`
+	Premium *PremiumOut `json:"premium,omitempty"`
+}
+
+type PremiumOut struct {
+	Start  int64 `json:"start"`
+	Finish int64 `json:"finish"`
+}
+
+type GroupsOut struct {
+	Groups []GroupOut `json:"groups"`
+}
+
+type GroupOut struct {
+	Sex       *string `json:"sex,omitempty"`
+	Status    *string `json:"status,omitempty"`
+	Interests *string `json:"interests,omitempty"`
+	Country   *string `json:"country,omitempty"`
+	City      *string `json:"city,omitempty"`
+	Count     int64   `json:"count"`
 }
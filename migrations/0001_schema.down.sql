@@ -2,4 +2,4 @@ DROP TABLE IF EXISTS likes;
 DROP TABLE IF EXISTS interest;
 DROP TABLE IF EXISTS account;
 DROP TABLE IF EXISTS city;
-DROP TABLE IF EXISTS country;
\ No newline at end of file
+DROP TABLE IF EXISTS country;
@@ -33,4 +33,4 @@ CREATE TABLE IF NOT EXISTS account (
     city_id     uuid default null,
     prem_start  timestamp default null,
     prem_end    timestamp default null
-);
\ No newline at end of file
+);
@@ -0,0 +1,17 @@
+-- foreign keys
+ALTER TABLE IF EXISTS account DROP CONSTRAINT IF EXISTS account_city_id_fkey;
+ALTER TABLE IF EXISTS account DROP CONSTRAINT IF EXISTS account_country_id_fkey;
+ALTER TABLE IF EXISTS likes DROP CONSTRAINT IF EXISTS likes_likee_id_fkey;
+ALTER TABLE IF EXISTS likes DROP CONSTRAINT IF EXISTS likes_liker_id_fkey;
+ALTER TABLE IF EXISTS interest DROP CONSTRAINT IF EXISTS interest_account_id_fkey;
+
+-- unique constraints
+ALTER TABLE IF EXISTS country DROP CONSTRAINT IF EXISTS unique_country_name;
+ALTER TABLE IF EXISTS city DROP CONSTRAINT IF EXISTS unique_city_name;
+ALTER TABLE IF EXISTS account DROP CONSTRAINT IF EXISTS unique_account_phone;
+ALTER TABLE IF EXISTS account DROP CONSTRAINT IF EXISTS unique_account_email;
+
+-- primary keys
+ALTER TABLE IF EXISTS account DROP CONSTRAINT IF EXISTS account_pkey;
+ALTER TABLE IF EXISTS country DROP CONSTRAINT IF EXISTS country_pkey;
+ALTER TABLE IF EXISTS city DROP CONSTRAINT IF EXISTS city_pkey;
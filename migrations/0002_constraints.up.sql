@@ -0,0 +1,47 @@
+-- every constraint is added only if it's missing, so the file can be re-run after a part of it
+-- was applied by hand or by a version of the file untracked in schema_migrations
+DO $$
+BEGIN
+    -- primary keys
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'city'::regclass AND conname = 'city_pkey') THEN
+        ALTER TABLE city ADD CONSTRAINT city_pkey PRIMARY KEY (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'country'::regclass AND conname = 'country_pkey') THEN
+        ALTER TABLE country ADD CONSTRAINT country_pkey PRIMARY KEY (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'account'::regclass AND conname = 'account_pkey') THEN
+        ALTER TABLE account ADD CONSTRAINT account_pkey PRIMARY KEY (id);
+    END IF;
+
+    -- unique constraints
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'account'::regclass AND conname = 'unique_account_email') THEN
+        ALTER TABLE account ADD CONSTRAINT unique_account_email UNIQUE (email);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'account'::regclass AND conname = 'unique_account_phone') THEN
+        ALTER TABLE account ADD CONSTRAINT unique_account_phone UNIQUE (phone);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'city'::regclass AND conname = 'unique_city_name') THEN
+        ALTER TABLE city ADD CONSTRAINT unique_city_name UNIQUE (name);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'country'::regclass AND conname = 'unique_country_name') THEN
+        ALTER TABLE country ADD CONSTRAINT unique_country_name UNIQUE (name);
+    END IF;
+
+    -- foreign keys
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'interest'::regclass AND conname = 'interest_account_id_fkey') THEN
+        ALTER TABLE interest ADD CONSTRAINT interest_account_id_fkey FOREIGN KEY (account_id) REFERENCES account (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'likes'::regclass AND conname = 'likes_liker_id_fkey') THEN
+        ALTER TABLE likes ADD CONSTRAINT likes_liker_id_fkey FOREIGN KEY (liker_id) REFERENCES account (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'likes'::regclass AND conname = 'likes_likee_id_fkey') THEN
+        ALTER TABLE likes ADD CONSTRAINT likes_likee_id_fkey FOREIGN KEY (likee_id) REFERENCES account (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'account'::regclass AND conname = 'account_country_id_fkey') THEN
+        ALTER TABLE account ADD CONSTRAINT account_country_id_fkey FOREIGN KEY (country_id) REFERENCES country (id);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'account'::regclass AND conname = 'account_city_id_fkey') THEN
+        ALTER TABLE account ADD CONSTRAINT account_city_id_fkey FOREIGN KEY (city_id) REFERENCES city (id);
+    END IF;
+END
+$$;
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS likes_liker_id_idx;
+DROP INDEX IF EXISTS likes_likee_id_idx;
+DROP INDEX IF EXISTS interest_name_account_id_idx;
+DROP INDEX IF EXISTS account_surname_trgm_idx;
+DROP INDEX IF EXISTS account_email_domain_idx;
+DROP INDEX IF EXISTS account_phone_code_idx;
+DROP INDEX IF EXISTS account_email_c_idx;
+DROP INDEX IF EXISTS account_country_id_idx;
+DROP INDEX IF EXISTS account_city_id_idx;
+DROP INDEX IF EXISTS account_joined_idx;
+DROP INDEX IF EXISTS account_birth_idx;
+DROP INDEX IF EXISTS account_sex_idx;
+DROP INDEX IF EXISTS account_status_idx;
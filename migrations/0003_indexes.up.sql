@@ -0,0 +1,29 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- account fields compared with a value
+CREATE INDEX IF NOT EXISTS account_status_idx ON account (status);
+CREATE INDEX IF NOT EXISTS account_sex_idx ON account (sex);
+CREATE INDEX IF NOT EXISTS account_birth_idx ON account (birth);
+CREATE INDEX IF NOT EXISTS account_joined_idx ON account (joined);
+CREATE INDEX IF NOT EXISTS account_city_id_idx ON account (city_id);
+CREATE INDEX IF NOT EXISTS account_country_id_idx ON account (country_id);
+
+-- email ranges are compared byte by byte, see repository.OpLt and repository.OpGt
+CREATE INDEX IF NOT EXISTS account_email_c_idx ON account (email COLLATE "C");
+
+-- expressions of the code and the domain filters, see repository.OpCode and repository.OpDomain
+CREATE INDEX IF NOT EXISTS account_phone_code_idx ON account ((substring(phone from '\((\d+)\)')));
+CREATE INDEX IF NOT EXISTS account_email_domain_idx ON account ((split_part(email, '@', 2)));
+
+-- prefix search of the starts filter
+CREATE INDEX IF NOT EXISTS account_surname_trgm_idx ON account USING gin (surname gin_trgm_ops);
+
+-- related tables
+CREATE INDEX IF NOT EXISTS interest_name_account_id_idx ON interest (name, account_id);
+CREATE INDEX IF NOT EXISTS likes_likee_id_idx ON likes (likee_id);
+CREATE INDEX IF NOT EXISTS likes_liker_id_idx ON likes (liker_id);
+
+-- the planner statistics of the loaded data, the dataset metrics are estimated by them as well
+ANALYZE account;
+ANALYZE interest;
+ANALYZE likes;
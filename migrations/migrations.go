@@ -0,0 +1,205 @@
+// Package migrations embeds the SQL of the schema and applies it tracking the applied versions.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"accounts/util"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// versionsTable records the applied migrations.
+const versionsTable = "schema_migrations"
+
+var (
+	createVersionsQuery = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    version     int primary key,
+    name        varchar(100) not null,
+    applied_at  timestamp not null default now()
+)`, versionsTable)
+	selectVersionsQuery = fmt.Sprintf(`SELECT version FROM %s`, versionsTable)
+	insertVersionQuery  = fmt.Sprintf(`INSERT INTO %s (version, name) VALUES ($1, $2)`, versionsTable)
+	deleteVersionQuery  = fmt.Sprintf(`DELETE FROM %s WHERE version = $1`, versionsTable)
+)
+
+// fileName is the name of a migration file, e.g. 0001_schema.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// deferredNames are the migrations applied after the bulk load, as the constraints and the indexes
+// slow it down and the likes refer to the accounts loaded later.
+var deferredNames = map[string]bool{
+	"constraints": true,
+	"indexes":     true,
+}
+
+type migration struct {
+	version  int
+	name     string
+	up       string
+	down     string
+	deferred bool
+}
+
+// migrations are the embedded files in the order of the versions.
+var migrations = mustList()
+
+func mustList() []migration {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		panic(err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: match[2], deferred: deferredNames[match[2]]}
+			byVersion[version] = m
+		}
+
+		if m.name != match[2] {
+			panic(fmt.Sprintf("migration %d is named both %s and %s", version, m.name, match[2]))
+		}
+
+		if match[3] == "up" {
+			m.up = entry.Name()
+		} else {
+			m.down = entry.Name()
+		}
+	}
+
+	list := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, *m)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+
+	return list
+}
+
+// Up applies the pending migrations which aren't deferred. The migrations are logged
+// by the logger of the context, see util.WithLogger, and aren't logged without one.
+func Up(ctx context.Context, conn *pgxpool.Pool) error {
+	return apply(ctx, conn, false)
+}
+
+// ApplyDeferred applies the pending migrations including the deferred ones,
+// it's run once the data is loaded.
+func ApplyDeferred(ctx context.Context, conn *pgxpool.Pool) error {
+	return apply(ctx, conn, true)
+}
+
+// Down reverts the last applied migration or, if all is set, every migration. The down files
+// only drop what exists, so the latter also drops a schema created before the versions were tracked.
+func Down(ctx context.Context, conn *pgxpool.Pool, all bool) error {
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if !applied[m.version] && !all {
+			continue
+		}
+
+		if err = run(ctx, conn, m, m.down, deleteVersionQuery, m.version); err != nil {
+			return err
+		}
+
+		util.LoggerFrom(ctx, nil).Info("migration reverted", "version", m.version, "name", m.name)
+		if !all {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, conn *pgxpool.Pool, deferred bool) error {
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if m.deferred && !deferred {
+			// the later migrations may depend on the deferred one
+			return nil
+		}
+
+		if err = run(ctx, conn, m, m.up, insertVersionQuery, m.version, m.name); err != nil {
+			return err
+		}
+
+		util.LoggerFrom(ctx, nil).Info("migration applied", "version", m.version, "name", m.name)
+	}
+
+	return nil
+}
+
+// run executes the file and records the version in one transaction, a file failing
+// half way is rolled back entirely and stays pending.
+func run(ctx context.Context, conn *pgxpool.Pool, m migration, file, versionQuery string, args ...interface{}) error {
+	sql, err := files.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			return fmt.Errorf("migration %d %s: %w", m.version, m.name, err)
+		}
+
+		_, err := tx.Exec(ctx, versionQuery, args...)
+		return err
+	})
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Pool) (map[int]bool, error) {
+	if _, err := conn.Exec(ctx, createVersionsQuery); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, selectVersionsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Migrations_Embedded(t *testing.T) {
+	require.Len(t, migrations, 3)
+	assert.Equal(t, "schema", migrations[0].name)
+	assert.False(t, migrations[0].deferred)
+	assert.True(t, migrations[1].deferred)
+	assert.True(t, migrations[2].deferred)
+
+	for i, m := range migrations {
+		if i > 0 {
+			assert.Greater(t, m.version, migrations[i-1].version, m.name)
+		}
+
+		for _, file := range []string{m.up, m.down} {
+			sql, err := files.ReadFile(file)
+			if assert.NoError(t, err, m.name) {
+				assert.NotEmpty(t, sql, file)
+			}
+		}
+	}
+}
+
+// Test_Migrations_DownIfExists checks that the down files run on a database missing the tables,
+// as Down with all runs them whether they were applied or not.
+func Test_Migrations_DownIfExists(t *testing.T) {
+	for _, m := range migrations {
+		sql, err := files.ReadFile(m.down)
+		require.NoError(t, err)
+
+		for _, statement := range strings.Split(string(sql), ";") {
+			statement = strings.TrimSpace(stripComments(statement))
+			if statement == "" {
+				continue
+			}
+
+			if strings.HasPrefix(statement, "ALTER TABLE") {
+				assert.True(t, strings.HasPrefix(statement, "ALTER TABLE IF EXISTS"), statement)
+			}
+
+			assert.Contains(t, statement, "IF EXISTS", statement)
+		}
+	}
+}
+
+// Test_Migrations_UpGuarded checks that the up files skip what exists, so they re-run
+// on a schema a part of them was applied to.
+func Test_Migrations_UpGuarded(t *testing.T) {
+	addConstraint := regexp.MustCompile(`ALTER TABLE (\w+) ADD CONSTRAINT (\w+)`)
+	for _, m := range migrations {
+		sql, err := files.ReadFile(m.up)
+		require.NoError(t, err)
+
+		for _, statement := range strings.Split(stripComments(string(sql)), ";") {
+			statement = strings.TrimSpace(statement)
+			switch {
+			case strings.HasPrefix(statement, "CREATE"):
+				assert.Contains(t, statement, "IF NOT EXISTS", statement)
+			case strings.Contains(statement, "ADD "):
+				match := addConstraint.FindStringSubmatch(statement)
+				if assert.NotNil(t, match, statement) {
+					guard := fmt.Sprintf("conrelid = '%s'::regclass AND conname = '%s'", match[1], match[2])
+					assert.Contains(t, statement, guard, statement)
+				}
+			}
+		}
+	}
+}
+
+func stripComments(statement string) string {
+	lines := strings.Split(statement, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			kept = append(kept, line)
+		}
+	}
+
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,123 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"accounts/app"
+	"accounts/app/controller"
+	"accounts/app/memory"
+	"accounts/app/service"
+)
+
+func Test_parsePhase(t *testing.T) {
+	p, err := parsePhase("ramp:100:300:10s")
+	require.NoError(t, err)
+	assert.Equal(t, phase{name: "ramp:100:300:10s", from: 100, to: 300, duration: 10 * time.Second}, p)
+
+	// the average rate of the ramp is 200 rps
+	assert.Equal(t, 2000, p.due(10*time.Second))
+	assert.Equal(t, 2000, p.due(time.Minute))
+	assert.Equal(t, 750, p.due(5*time.Second))
+
+	p, err = parsePhase("fixed:50:1m")
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, p.from)
+	assert.Equal(t, 50.0, p.to)
+
+	for _, invalid := range []string{"fixed:50", "ramp:1:2", "fixed:x:1s", "fixed:1:0s", "step:1:1s"} {
+		_, err = parsePhase(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func Test_percentile(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(latencies, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(latencies, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(latencies, 100))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func Test_distribution_Pick(t *testing.T) {
+	d := newDistribution()
+	for i := 0; i < 9; i++ {
+		d.Add("frequent")
+	}
+	d.Add("rare")
+	d.freeze()
+
+	rnd := rand.New(rand.NewSource(1))
+	picked := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		picked[d.Pick(rnd)]++
+	}
+
+	assert.InDelta(t, 9000, picked["frequent"], 300)
+	assert.InDelta(t, 1000, picked["rare"], 300)
+}
+
+// Test_generator_ValidQueries checks that the server accepts the generated queries.
+func Test_generator_ValidQueries(t *testing.T) {
+	router := app.Router(controller.New(service.New(memory.New())), nil, nil)
+
+	var accounts []string
+	for id := 1; id <= 50; id++ {
+		accounts = append(accounts, fmt.Sprintf(`{"id": %d, "email": "u%d@mail.ru", "sex": "%s", "fname": "Имя%d",
+			"sname": "Фамилия%d", "phone": "8(9%02d)12345%02d", "country": "Страна%d", "city": "Город%d",
+			"birth": %d, "joined": %d, "status": "свободны", "interests": ["интерес%d", "интерес%d"]}`,
+			id, id, []string{"m", "f"}[id%2], id%5, id%7, id%10, id, id%3, id%6,
+			time.Date(1980+id%20, 1, 2, 0, 0, 0, 0, time.UTC).Unix(),
+			time.Date(2011+id%7, 1, 2, 0, 0, 0, 0, time.UTC).Unix(), id%4, id%9))
+	}
+
+	for _, account := range accounts {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/accounts/new/", strings.NewReader(account)))
+		require.Equal(t, http.StatusCreated, w.Code, account)
+	}
+
+	d := newDataset()
+	require.NoError(t, readAccounts(strings.NewReader(`{"accounts": [`+strings.Join(accounts, ",")+`]}`), d))
+	d.freeze()
+
+	mix, err := parseMix(defaultMix)
+	require.NoError(t, err)
+
+	g := newGenerator(d, mix, 1)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	r := &runner{client: server.Client(), addr: server.URL, gen: g, maxInFlight: 10}
+	st := r.run(context.Background(), phase{name: "fixed", from: 500, to: 500, duration: 200 * time.Millisecond})
+
+	requests, errors := 0, 0
+	for _, e := range st.endpoints {
+		requests += len(e.latencies) + e.dropped
+		errors += e.errors
+	}
+
+	assert.Equal(t, 100, requests)
+	assert.Equal(t, 0, errors)
+
+	for i := 0; i < 1000; i++ {
+		q := g.Next()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(q.method, q.uri, bytes.NewReader(q.body)))
+		require.Equal(t, expectedStatus[q.endpoint], w.Code, "%s %s %s", q.method, q.uri, q.body)
+	}
+}
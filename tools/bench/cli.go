@@ -0,0 +1,132 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagAddr        = "addr"
+	flagData        = "data"
+	flagPhase       = "phase"
+	flagMix         = "mix"
+	flagMaxInFlight = "max-in-flight"
+	flagTimeout     = "timeout"
+	flagSeed        = "seed"
+)
+
+var (
+	errEmptyDataset = errors.New("dataset has no accounts")
+	errNoPhases     = errors.New("no phases")
+)
+
+func Run() error {
+	app := cli.NewApp()
+	app.Usage = "loads the server with the random queries sampled from the dataset"
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagAddr,
+			Usage: "address of the server",
+			Value: "http://127.0.0.1:8888",
+		},
+		&cli.StringSliceFlag{
+			Name:     flagData,
+			Usage:    "data.zip or accounts_N.json files the server is loaded with",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  flagPhase,
+			Usage: "phases run one after another: fixed:RPS:DURATION or ramp:FROM:TO:DURATION",
+			Value: cli.NewStringSlice("ramp:100:1000:30s", "fixed:1000:30s"),
+		},
+		&cli.StringFlag{
+			Name:  flagMix,
+			Usage: "shares of the requests per endpoint",
+			Value: defaultMix,
+		},
+		&cli.IntFlag{
+			Name:  flagMaxInFlight,
+			Usage: "number of requests in flight, the requests over it are dropped",
+			Value: 1000,
+		},
+		&cli.DurationFlag{
+			Name:  flagTimeout,
+			Usage: "timeout of a request",
+			Value: 2 * time.Second,
+		},
+		&cli.Int64Flag{
+			Name:  flagSeed,
+			Usage: "seed of the random queries, the same seed generates the same queries",
+			Value: 1,
+		},
+	}
+
+	app.Action = run
+
+	return app.Run(os.Args)
+}
+
+func run(ctx *cli.Context) error {
+	phases := make([]phase, 0)
+	for _, s := range ctx.StringSlice(flagPhase) {
+		p, err := parsePhase(s)
+		if err != nil {
+			return err
+		}
+
+		phases = append(phases, p)
+	}
+
+	if len(phases) == 0 {
+		return errNoPhases
+	}
+
+	mix, err := parseMix(ctx.String(flagMix))
+	if err != nil {
+		return err
+	}
+
+	maxInFlight := ctx.Int(flagMaxInFlight)
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+
+	start := time.Now()
+	d, err := readDataset(ctx.StringSlice(flagData))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%d accounts read in %s", len(d.ids), time.Since(start))
+
+	r := &runner{
+		client: &http.Client{
+			Timeout:   ctx.Duration(flagTimeout),
+			Transport: &http.Transport{MaxIdleConnsPerHost: maxInFlight},
+		},
+		addr:        strings.TrimSuffix(ctx.String(flagAddr), "/"),
+		gen:         newGenerator(d, mix, ctx.Int64(flagSeed)),
+		maxInFlight: maxInFlight,
+	}
+
+	for _, p := range phases {
+		log.Printf("phase %s", p.name)
+		st := r.run(ctx.Context, p)
+
+		fmt.Printf("\nphase %s\n", p.name)
+		st.Print(os.Stdout)
+
+		if err = ctx.Context.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+package bench
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"accounts/util"
+)
+
+// account is the part of the dataset account the values of the queries are sampled from.
+type account struct {
+	ID        int32    `json:"id"`
+	Email     string   `json:"email"`
+	Sex       string   `json:"sex"`
+	Birth     int64    `json:"birth"`
+	Joined    int64    `json:"joined"`
+	Status    string   `json:"status"`
+	Name      *string  `json:"fname"`
+	Surname   *string  `json:"sname"`
+	Phone     *string  `json:"phone"`
+	Country   *string  `json:"country"`
+	City      *string  `json:"city"`
+	Interests []string `json:"interests"`
+}
+
+// distribution samples the values in proportion to their occurrences in the dataset.
+type distribution struct {
+	counts     map[string]int
+	values     []string
+	cumulative []int
+}
+
+func newDistribution() *distribution {
+	return &distribution{counts: make(map[string]int)}
+}
+
+func (d *distribution) Add(value string) {
+	d.counts[value]++
+}
+
+// freeze prepares the distribution for sampling, it's called once the dataset is read.
+func (d *distribution) freeze() {
+	d.values = make([]string, 0, len(d.counts))
+	for value := range d.counts {
+		d.values = append(d.values, value)
+	}
+
+	// sorted for the runs with the same seed to produce the same queries
+	sort.Strings(d.values)
+
+	d.cumulative = make([]int, len(d.values))
+	total := 0
+	for i, value := range d.values {
+		total += d.counts[value]
+		d.cumulative[i] = total
+	}
+}
+
+func (d *distribution) Empty() bool {
+	return len(d.values) == 0
+}
+
+// Pick returns a random value, the frequent ones more often.
+func (d *distribution) Pick(rnd *rand.Rand) string {
+	if d.Empty() {
+		return ""
+	}
+
+	n := rnd.Intn(d.cumulative[len(d.cumulative)-1])
+	return d.values[sort.SearchInts(d.cumulative, n+1)]
+}
+
+// dataset keeps the distributions of the values of the loaded accounts.
+type dataset struct {
+	ids        []int32
+	maxID      int32
+	sex        *distribution
+	status     *distribution
+	fname      *distribution
+	snamePart  *distribution // surname prefixes for sname_starts
+	domain     *distribution
+	phoneCode  *distribution
+	country    *distribution
+	city       *distribution
+	interests  *distribution
+	birthYear  *distribution
+	joinedYear *distribution
+}
+
+func newDataset() *dataset {
+	return &dataset{
+		sex:        newDistribution(),
+		status:     newDistribution(),
+		fname:      newDistribution(),
+		snamePart:  newDistribution(),
+		domain:     newDistribution(),
+		phoneCode:  newDistribution(),
+		country:    newDistribution(),
+		city:       newDistribution(),
+		interests:  newDistribution(),
+		birthYear:  newDistribution(),
+		joinedYear: newDistribution(),
+	}
+}
+
+func (d *dataset) add(a account) {
+	d.ids = append(d.ids, a.ID)
+	if a.ID > d.maxID {
+		d.maxID = a.ID
+	}
+
+	d.sex.Add(a.Sex)
+	d.status.Add(a.Status)
+	d.domain.Add(a.Email[strings.LastIndex(a.Email, "@")+1:])
+	d.birthYear.Add(year(a.Birth))
+	d.joinedYear.Add(year(a.Joined))
+	if a.Name != nil {
+		d.fname.Add(*a.Name)
+	}
+	if a.Surname != nil {
+		if prefix := []rune(*a.Surname); len(prefix) > 3 {
+			d.snamePart.Add(string(prefix[:3]))
+		} else {
+			d.snamePart.Add(*a.Surname)
+		}
+	}
+	if a.Phone != nil {
+		if start, end := strings.Index(*a.Phone, "("), strings.Index(*a.Phone, ")"); start >= 0 && end > start {
+			d.phoneCode.Add((*a.Phone)[start+1 : end])
+		}
+	}
+	if a.Country != nil {
+		d.country.Add(*a.Country)
+	}
+	if a.City != nil {
+		d.city.Add(*a.City)
+	}
+	for _, interest := range a.Interests {
+		d.interests.Add(interest)
+	}
+}
+
+func (d *dataset) freeze() {
+	for _, dist := range []*distribution{d.sex, d.status, d.fname, d.snamePart, d.domain, d.phoneCode,
+		d.country, d.city, d.interests, d.birthYear, d.joinedYear} {
+		dist.freeze()
+	}
+}
+
+// RandomID returns the id of a random loaded account.
+func (d *dataset) RandomID(rnd *rand.Rand) int32 {
+	return d.ids[rnd.Intn(len(d.ids))]
+}
+
+// readDataset reads the accounts of data.zip or of the json files.
+func readDataset(paths []string) (*dataset, error) {
+	d := newDataset()
+	for _, path := range paths {
+		var err error
+		if filepath.Ext(path) == ".zip" {
+			err = readArchive(path, d)
+		} else {
+			err = readJSONFile(path, d)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(d.ids) == 0 {
+		return nil, errEmptyDataset
+	}
+
+	d.freeze()
+	return d, nil
+}
+
+func readArchive(path string, d *dataset) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if !strings.HasPrefix(file.Name, "accounts_") || filepath.Ext(file.Name) != ".json" {
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		err = readAccounts(r, d)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readJSONFile(path string, d *dataset) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	return readAccounts(bufio.NewReader(file), d)
+}
+
+func readAccounts(r io.Reader, d *dataset) error {
+	return util.DecodeArray(r, "accounts", func(dec *json.Decoder) error {
+		var a account
+		if err := dec.Decode(&a); err != nil {
+			return err
+		}
+
+		d.add(a)
+		return nil
+	})
+}
+
+func year(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format("2006")
+}
@@ -0,0 +1,325 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	endpointFilter    = "filter"
+	endpointGroup     = "group"
+	endpointRecommend = "recommend"
+	endpointSuggest   = "suggest"
+	endpointNew       = "new"
+	endpointUpdate    = "update"
+	endpointLikes     = "likes"
+)
+
+// defaultMix is the share of the requests per endpoint.
+const defaultMix = "filter=50,group=20,recommend=10,suggest=10,new=4,update=3,likes=3"
+
+// expectedStatus is the status of a successful response per endpoint.
+var expectedStatus = map[string]int{
+	endpointFilter:    http.StatusOK,
+	endpointGroup:     http.StatusOK,
+	endpointRecommend: http.StatusOK,
+	endpointSuggest:   http.StatusOK,
+	endpointNew:       http.StatusCreated,
+	endpointUpdate:    http.StatusAccepted,
+	endpointLikes:     http.StatusAccepted,
+}
+
+// query is a generated request.
+type query struct {
+	endpoint string
+	method   string
+	uri      string
+	body     []byte
+}
+
+type weighted struct {
+	endpoint string
+	weight   int
+}
+
+// parseMix parses the shares of the endpoints, e.g. filter=50,group=20.
+func parseMix(s string) ([]weighted, error) {
+	mix := make([]weighted, 0)
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid mix %q, expected endpoint=weight", part)
+		}
+
+		if _, ok := expectedStatus[kv[0]]; !ok {
+			return nil, fmt.Errorf("unknown endpoint %q", kv[0])
+		}
+
+		weight, err := strconv.Atoi(kv[1])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight of %s: %q", kv[0], kv[1])
+		}
+
+		if weight > 0 {
+			mix = append(mix, weighted{endpoint: kv[0], weight: weight})
+		}
+	}
+
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("empty mix %q", s)
+	}
+
+	return mix, nil
+}
+
+// generator generates random queries with the values sampled from the dataset,
+// it isn't safe for concurrent use.
+type generator struct {
+	d       *dataset
+	rnd     *rand.Rand
+	mix     []weighted
+	total   int
+	nextID  int32
+	queryID int
+}
+
+func newGenerator(d *dataset, mix []weighted, seed int64) *generator {
+	g := &generator{d: d, rnd: rand.New(rand.NewSource(seed)), mix: mix, nextID: d.maxID}
+	for _, w := range mix {
+		g.total += w.weight
+	}
+
+	return g
+}
+
+func (g *generator) Next() query {
+	n := g.rnd.Intn(g.total)
+	endpoint := g.mix[len(g.mix)-1].endpoint
+	for _, w := range g.mix {
+		if n < w.weight {
+			endpoint = w.endpoint
+			break
+		}
+
+		n -= w.weight
+	}
+
+	g.queryID++
+	switch endpoint {
+	case endpointFilter:
+		return g.get(endpoint, "/accounts/filter/", g.filterParams(1+g.rnd.Intn(3)))
+	case endpointGroup:
+		return g.get(endpoint, "/accounts/group/", g.groupParams())
+	case endpointRecommend, endpointSuggest:
+		return g.get(endpoint, fmt.Sprintf("/accounts/%d/%s/", g.d.RandomID(g.rnd), endpoint), g.locationParams())
+	case endpointNew:
+		return g.post(endpoint, "/accounts/new/", g.newAccount())
+	case endpointUpdate:
+		return g.post(endpoint, fmt.Sprintf("/accounts/%d/", g.d.RandomID(g.rnd)), g.accountUpdate())
+	}
+
+	return g.post(endpointLikes, "/accounts/likes/", g.likes())
+}
+
+func (g *generator) get(endpoint, path string, params url.Values) query {
+	params.Set("query_id", strconv.Itoa(g.queryID))
+	return query{endpoint: endpoint, method: http.MethodGet, uri: path + "?" + params.Encode()}
+}
+
+func (g *generator) post(endpoint, path string, body interface{}) query {
+	data, _ := json.Marshal(body)
+	return query{
+		endpoint: endpoint,
+		method:   http.MethodPost,
+		uri:      path + "?query_id=" + strconv.Itoa(g.queryID),
+		body:     data,
+	}
+}
+
+// filterPredicates are the filter parameters with the random values.
+func (g *generator) filterPredicates() map[string]func() string {
+	return map[string]func() string{
+		"sex_eq":             func() string { return g.d.sex.Pick(g.rnd) },
+		"status_eq":          func() string { return g.d.status.Pick(g.rnd) },
+		"status_neq":         func() string { return g.d.status.Pick(g.rnd) },
+		"fname_eq":           func() string { return g.d.fname.Pick(g.rnd) },
+		"fname_any":          func() string { return g.picks(g.d.fname, 2+g.rnd.Intn(3)) },
+		"fname_null":         g.null,
+		"sname_starts":       func() string { return g.d.snamePart.Pick(g.rnd) },
+		"sname_null":         g.null,
+		"email_domain":       func() string { return g.d.domain.Pick(g.rnd) },
+		"phone_code":         func() string { return g.d.phoneCode.Pick(g.rnd) },
+		"phone_null":         g.null,
+		"country_eq":         func() string { return g.d.country.Pick(g.rnd) },
+		"country_null":       g.null,
+		"city_eq":            func() string { return g.d.city.Pick(g.rnd) },
+		"city_any":           func() string { return g.picks(g.d.city, 2+g.rnd.Intn(3)) },
+		"city_null":          g.null,
+		"birth_year":         func() string { return g.d.birthYear.Pick(g.rnd) },
+		"birth_lt":           func() string { return g.yearStart(g.d.birthYear) },
+		"birth_gt":           func() string { return g.yearStart(g.d.birthYear) },
+		"interests_contains": func() string { return g.picks(g.d.interests, 1+g.rnd.Intn(2)) },
+		"interests_any":      func() string { return g.picks(g.d.interests, 2+g.rnd.Intn(3)) },
+		"likes_contains":     func() string { return g.ids(1 + g.rnd.Intn(2)) },
+		"premium_now":        func() string { return "1" },
+		"premium_null":       g.null,
+	}
+}
+
+func (g *generator) filterParams(n int) url.Values {
+	predicates := g.filterPredicates()
+	names := make([]string, 0, len(predicates))
+	for name := range predicates {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	g.rnd.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+
+	params := url.Values{}
+	fields := make(map[string]bool)
+	for _, name := range names {
+		field := name[:strings.IndexByte(name, '_')]
+		if fields[field] {
+			continue
+		}
+
+		fields[field] = true
+		params.Set(name, predicates[name]())
+		if len(fields) == n {
+			break
+		}
+	}
+
+	params.Set("limit", strconv.Itoa(1+g.rnd.Intn(50)))
+	return params
+}
+
+var groupKeys = [][]string{
+	{"sex"}, {"status"}, {"interests"}, {"country"}, {"city"},
+	{"sex", "status"}, {"country", "sex"}, {"city", "sex"}, {"city", "status"}, {"country", "status"},
+}
+
+func (g *generator) groupParams() url.Values {
+	params := url.Values{}
+	switch g.rnd.Intn(6) {
+	case 0:
+		params.Set("birth", g.d.birthYear.Pick(g.rnd))
+	case 1:
+		params.Set("joined", g.d.joinedYear.Pick(g.rnd))
+	case 2:
+		params.Set("interests", g.d.interests.Pick(g.rnd))
+	case 3:
+		params.Set("likes", strconv.Itoa(int(g.d.RandomID(g.rnd))))
+	case 4:
+		params.Set("city", g.d.city.Pick(g.rnd))
+	}
+
+	params.Set("keys", strings.Join(groupKeys[g.rnd.Intn(len(groupKeys))], ","))
+	params.Set("order", []string{"1", "-1"}[g.rnd.Intn(2)])
+	params.Set("limit", strconv.Itoa(1+g.rnd.Intn(50)))
+	return params
+}
+
+func (g *generator) locationParams() url.Values {
+	params := url.Values{}
+	switch g.rnd.Intn(3) {
+	case 0:
+		params.Set("country", g.d.country.Pick(g.rnd))
+	case 1:
+		params.Set("city", g.d.city.Pick(g.rnd))
+	}
+
+	params.Set("limit", strconv.Itoa(1+g.rnd.Intn(20)))
+	return params
+}
+
+func (g *generator) newAccount() map[string]interface{} {
+	g.nextID++
+	birth := g.yearTs(g.d.birthYear) + g.rnd.Int63n(365*24*3600)
+	joined := g.yearTs(g.d.joinedYear) + g.rnd.Int63n(365*24*3600)
+	account := map[string]interface{}{
+		"id":        g.nextID,
+		"email":     fmt.Sprintf("bench%d@%s", g.nextID, g.d.domain.Pick(g.rnd)),
+		"sex":       g.d.sex.Pick(g.rnd),
+		"birth":     birth,
+		"joined":    joined,
+		"status":    g.d.status.Pick(g.rnd),
+		"interests": strings.Split(g.picks(g.d.interests, 1+g.rnd.Intn(5)), ","),
+	}
+
+	if !g.d.city.Empty() {
+		account["city"] = g.d.city.Pick(g.rnd)
+	}
+
+	return account
+}
+
+func (g *generator) accountUpdate() map[string]interface{} {
+	switch g.rnd.Intn(3) {
+	case 0:
+		return map[string]interface{}{"status": g.d.status.Pick(g.rnd)}
+	case 1:
+		return map[string]interface{}{"city": g.d.city.Pick(g.rnd)}
+	}
+
+	return map[string]interface{}{"interests": strings.Split(g.picks(g.d.interests, 1+g.rnd.Intn(5)), ",")}
+}
+
+func (g *generator) likes() map[string]interface{} {
+	likes := make([]map[string]interface{}, 0)
+	now := time.Now().Unix()
+	for i := 1 + g.rnd.Intn(5); i > 0; i-- {
+		likes = append(likes, map[string]interface{}{
+			"liker": g.d.RandomID(g.rnd),
+			"likee": g.d.RandomID(g.rnd),
+			"ts":    now - g.rnd.Int63n(365*24*3600),
+		})
+	}
+
+	return map[string]interface{}{"likes": likes}
+}
+
+func (g *generator) null() string {
+	return strconv.Itoa(g.rnd.Intn(2))
+}
+
+// picks returns n distinct values separated by commas.
+func (g *generator) picks(d *distribution, n int) string {
+	picked := make([]string, 0, n)
+	seen := make(map[string]bool)
+	for attempt := 0; len(picked) < n && attempt < 10*n; attempt++ {
+		value := d.Pick(g.rnd)
+		if !seen[value] {
+			seen[value] = true
+			picked = append(picked, value)
+		}
+	}
+
+	return strings.Join(picked, ",")
+}
+
+func (g *generator) ids(n int) string {
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ids = append(ids, strconv.Itoa(int(g.d.RandomID(g.rnd))))
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func (g *generator) yearTs(d *distribution) int64 {
+	year, _ := strconv.Atoi(d.Pick(g.rnd))
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).Unix()
+}
+
+func (g *generator) yearStart(d *distribution) string {
+	return strconv.FormatInt(g.yearTs(d), 10)
+}
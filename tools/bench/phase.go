@@ -0,0 +1,153 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	phaseFixed = "fixed"
+	phaseRamp  = "ramp"
+
+	// tick is the interval the requests due are sent at.
+	tick = 5 * time.Millisecond
+)
+
+// phase sends the requests at the rate changing linearly from the initial to the final one.
+type phase struct {
+	name     string
+	from     float64
+	to       float64
+	duration time.Duration
+}
+
+// parsePhase parses fixed:RPS:DURATION or ramp:FROM:TO:DURATION, e.g. ramp:100:2000:1m.
+func parsePhase(s string) (phase, error) {
+	parts := strings.Split(s, ":")
+	p := phase{name: s}
+
+	var rates []string
+	switch {
+	case parts[0] == phaseFixed && len(parts) == 3:
+		rates = []string{parts[1], parts[1]}
+	case parts[0] == phaseRamp && len(parts) == 4:
+		rates = parts[1:3]
+	default:
+		return p, fmt.Errorf("invalid phase %q, expected fixed:RPS:DURATION or ramp:FROM:TO:DURATION", s)
+	}
+
+	var err error
+	if p.from, err = strconv.ParseFloat(rates[0], 64); err != nil || p.from < 0 {
+		return p, fmt.Errorf("invalid rate of phase %q", s)
+	}
+
+	if p.to, err = strconv.ParseFloat(rates[1], 64); err != nil || p.to < 0 {
+		return p, fmt.Errorf("invalid rate of phase %q", s)
+	}
+
+	if p.duration, err = time.ParseDuration(parts[len(parts)-1]); err != nil || p.duration <= 0 {
+		return p, fmt.Errorf("invalid duration of phase %q", s)
+	}
+
+	return p, nil
+}
+
+// due is the number of the requests to be sent by the elapsed time, the integral of the rate.
+func (p phase) due(elapsed time.Duration) int {
+	if elapsed > p.duration {
+		elapsed = p.duration
+	}
+
+	t := elapsed.Seconds()
+	return int(p.from*t + (p.to-p.from)*t*t/(2*p.duration.Seconds()))
+}
+
+// runner sends the generated requests to the server.
+type runner struct {
+	client      *http.Client
+	addr        string
+	gen         *generator
+	maxInFlight int
+}
+
+// run sends the requests of the phase on schedule regardless of the responses, as the contest does.
+// The requests exceeding maxInFlight are dropped, so that an overloaded server doesn't slow the schedule down.
+func (r *runner) run(ctx context.Context, p phase) *stats {
+	st := newStats(p.duration)
+	inFlight := make(chan struct{}, r.maxInFlight)
+	var wg sync.WaitGroup
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	sent := 0
+	for {
+		elapsed := time.Since(start)
+		for due := p.due(elapsed); sent < due; sent++ {
+			q := r.gen.Next()
+			select {
+			case inFlight <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-inFlight }()
+					r.send(ctx, q, st)
+				}()
+			default:
+				st.drop(q.endpoint)
+			}
+		}
+
+		if elapsed >= p.duration {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return st
+		case <-ticker.C:
+		}
+	}
+
+	wg.Wait()
+	return st
+}
+
+func (r *runner) send(ctx context.Context, q query, st *stats) {
+	var body io.Reader
+	if q.body != nil {
+		body = bytes.NewReader(q.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, q.method, r.addr+q.uri, body)
+	if err != nil {
+		st.record(q.endpoint, 0, false)
+		return
+	}
+
+	if q.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if err != nil {
+		st.record(q.endpoint, time.Since(start), false)
+		return
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	st.record(q.endpoint, time.Since(start), resp.StatusCode == expectedStatus[q.endpoint])
+}
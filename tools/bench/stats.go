@@ -0,0 +1,117 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// endpointStats are the latencies and the outcomes of the requests of an endpoint.
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+	dropped   int
+}
+
+// stats collects the results of a phase, it's safe for concurrent use.
+type stats struct {
+	mu        sync.Mutex
+	duration  time.Duration
+	endpoints map[string]*endpointStats
+}
+
+func newStats(duration time.Duration) *stats {
+	return &stats{duration: duration, endpoints: make(map[string]*endpointStats)}
+}
+
+func (s *stats) endpoint(name string) *endpointStats {
+	e, ok := s.endpoints[name]
+	if !ok {
+		e = &endpointStats{}
+		s.endpoints[name] = e
+	}
+
+	return e
+}
+
+// record adds the latency of a request, failed are the transport errors and the unexpected statuses.
+func (s *stats) record(endpoint string, latency time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.endpoint(endpoint)
+	e.latencies = append(e.latencies, latency)
+	if !ok {
+		e.errors++
+	}
+}
+
+// drop counts a request not sent as too many requests were in flight.
+func (s *stats) drop(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.endpoint(endpoint).dropped++
+}
+
+// Print writes the rate, the percentiles of the latencies and the errors per endpoint and in total.
+func (s *stats) Print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.endpoints))
+	total := &endpointStats{}
+	for name, e := range s.endpoints {
+		names = append(names, name)
+		total.latencies = append(total.latencies, e.latencies...)
+		total.errors += e.errors
+		total.dropped += e.dropped
+	}
+
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "endpoint\trequests\trps\tp50\tp95\tp99\tmax\terrors\terror %\tdropped\t")
+	for _, name := range names {
+		s.printRow(tw, name, s.endpoints[name])
+	}
+	s.printRow(tw, "total", total)
+	tw.Flush()
+}
+
+func (s *stats) printRow(w io.Writer, name string, e *endpointStats) {
+	sorted := make([]time.Duration, len(e.latencies))
+	copy(sorted, e.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	requests := len(sorted)
+	errorRate := 0.0
+	if requests > 0 {
+		errorRate = 100 * float64(e.errors) / float64(requests)
+	}
+
+	fmt.Fprintf(w, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%d\t%.2f\t%d\t\n", name, requests,
+		float64(requests)/s.duration.Seconds(), percentile(sorted, 50), percentile(sorted, 95),
+		percentile(sorted, 99), percentile(sorted, 100), e.errors, errorRate, e.dropped)
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank].Round(time.Microsecond)
+}
@@ -1,28 +1,28 @@
 package dataloader
 
 import (
+	"bufio"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"strings"
+	"runtime"
 	"time"
 
-	"github.com/google/uuid"
-	_ "github.com/jackc/pgx/stdlib"
-	"github.com/jmoiron/sqlx"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/urfave/cli/v2"
 
-	"accounts/domain"
+	"accounts/migrations"
+	"accounts/util"
 )
 
 const (
-	flagConn = "conn"
+	flagConn    = "conn"
+	flagBatch   = "batch"
+	flagWorkers = "workers"
+	flagAll     = "all"
 
-	timestampLayout = "2006-01-02 15:04:05"
-	nullTime        = "0000-00-00 00:00:00"
+	defaultBatchSize = 10000
 )
 
 var (
@@ -32,6 +32,7 @@ var (
 
 func Run() error {
 	app := cli.NewApp()
+	app.Usage = "manages the schema and loads the accounts into postgres"
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
 			Name:     flagConn,
@@ -40,272 +41,180 @@ func Run() error {
 		},
 	}
 
-	app.Action = run
-
-	return app.Run(os.Args)
-}
-
-func run(ctx *cli.Context) error {
-	connStr := ctx.String(flagConn)
-	if connStr == "" {
-		return errEmptyConn
-	}
-
-	conn, err := sqlx.Connect("pgx", connStr)
-	if err != nil {
-		return err
-	}
-
-	defer conn.Close()
-
-	accounts, err := readFile(ctx.Args().Slice())
-	if err != nil {
-		return err
-	}
-
-	if err = writeToDB(conn, accounts); err != nil {
-		return err
+	app.Commands = []*cli.Command{
+		{
+			Name:  "migrate",
+			Usage: "applies or reverts the schema migrations",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "up",
+					Usage:  "applies the pending migrations, the constraints and the indexes are left for constraints apply",
+					Action: withConn(migrateUp),
+				},
+				{
+					Name:  "down",
+					Usage: "reverts the last applied migration, the checkpoints of the load are dropped with the schema",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  flagAll,
+							Usage: "revert every applied migration",
+						},
+					},
+					Action: withConn(migrateDown),
+				},
+			},
+		},
+		{
+			Name:  "constraints",
+			Usage: "manages the constraints and the indexes deferred until the data is loaded",
+			Subcommands: []*cli.Command{
+				{
+					Name:   "apply",
+					Usage:  "applies the pending migrations including the constraints",
+					Action: withConn(applyConstraints),
+				},
+			},
+		},
+		{
+			Name:      "load",
+			Usage:     "migrates the schema, loads the files and applies the constraints",
+			ArgsUsage: "accounts_1.json [accounts_2.json ...]",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  flagBatch,
+					Usage: "number of accounts decoded and written at once",
+					Value: defaultBatchSize,
+				},
+				&cli.IntFlag{
+					Name:  flagWorkers,
+					Usage: "number of files loaded concurrently, the number of CPUs by default",
+				},
+			},
+			Action: withConn(load),
+		},
 	}
 
-	return nil
+	return app.Run(os.Args)
 }
 
-func readFile(paths []string) ([]Account, error) {
-	if len(paths) == 0 {
-		return nil, errEmptyArg
-	}
-
-	result := make([]Account, 0)
-	for _, path := range paths {
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			return nil, err
+// withConn connects to the database for the action.
+func withConn(action func(ctx *cli.Context, conn *pgxpool.Pool) error) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		connStr := ctx.String(flagConn)
+		if connStr == "" {
+			return errEmptyConn
 		}
 
-		var accounts Accounts
-		if err = json.Unmarshal(data, &accounts); err != nil {
-			return nil, err
+		conn, err := pgxpool.Connect(ctx.Context, connStr)
+		if err != nil {
+			return err
 		}
 
-		result = append(result, accounts.Accounts...)
-	}
+		defer conn.Close()
 
-	return result, nil
-}
+		// the migrations log by the logger of the context
+		ctx.Context = util.WithLogger(ctx.Context, util.NewLogger(os.Stderr, util.LevelInfo))
 
-func writeToDB(conn *sqlx.DB, accounts []Account) error {
-	countries, cities, err := writeCountriesAndCities(conn, accounts)
-	if err != nil {
-		return err
+		return action(ctx, conn)
 	}
-
-	if err = writeAccounts(conn, accounts, countries, cities); err != nil {
-		return err
-	}
-
-	return writeLikesAndInterests(conn, accounts)
 }
 
-func writeCountriesAndCities(conn *sqlx.DB, accs []Account) (countries, cities map[string]uuid.UUID, err error) {
-	countries = make(map[string]uuid.UUID)
-	cities = make(map[string]uuid.UUID)
-
-	for _, acc := range accs {
-		if acc.Country != nil {
-			countries[*acc.Country] = uuid.New()
-		}
-
-		if acc.City != nil {
-			cities[*acc.City] = uuid.New()
-		}
-	}
-
-	queryTotal := `INSERT INTO %s(id, name) VALUES %s;`
-
-	queriesCity := make([]string, 0, len(cities))
-	for name, id := range cities {
-		queriesCity = append(queriesCity, fmt.Sprintf(`('%s'::uuid, '%s')`, id, name))
-	}
-
-	if _, err = conn.Exec(fmt.Sprintf(queryTotal, "city", strings.Join(queriesCity, ", "))); err != nil {
-		return
-	}
-
-	queriesCountry := make([]string, 0, len(countries))
-	for name, id := range countries {
-		queriesCountry = append(queriesCountry, fmt.Sprintf(`('%s'::uuid, '%s')`, id, name))
-	}
-
-	_, err = conn.Exec(fmt.Sprintf(queryTotal, "country", strings.Join(queriesCountry, ", ")))
-	return
+func migrateUp(ctx *cli.Context, conn *pgxpool.Pool) error {
+	return migrations.Up(ctx.Context, conn)
 }
 
-func writeAccounts(conn *sqlx.DB, accs []Account, countries, cities map[string]uuid.UUID) error {
-	accounts := make([]domain.AccountModel, 0, len(accs))
-
-	for _, acc := range accs {
-
-		var countryID, cityID *uuid.UUID
-		if acc.Country != nil {
-			countryID = ptrUUID(countries[*acc.Country])
-		}
-
-		if acc.City != nil {
-			cityID = ptrUUID(cities[*acc.City])
-		}
-
-		accounts = append(accounts, newAccount(&acc, countryID, cityID))
-	}
-
-	queryAccountTotal := `INSERT INTO account(id, email, sex, status, birth, name, surname, phone, country_id, city_id, joined, prem_start, prem_end) VALUES %s;`
-	queriesAccount := make([]string, 0, len(accounts))
-	for _, a := range accounts {
-		query := fmt.Sprintf(
-			`(%d, '%s', '%s', '%s', %s, %s, %s, %s, %s, %s, %s, %s, %s)`,
-			a.ID, a.Email, a.Sex, a.Status,
-			nullableTimestamp(&a.Birth),
-			nullableString(a.Name),
-			nullableString(a.Surname),
-			nullableString(a.Phone),
-			nullableUUID(a.CountryID),
-			nullableUUID(a.CityID),
-			nullableTimestamp(&a.Joined),
-			nullableTimestamp(a.PremiumStart),
-			nullableTimestamp(a.PremiumEnd),
-		)
-
-		queriesAccount = append(queriesAccount, query)
-	}
-
-	query := strings.Join(queriesAccount, ", ")
-	if _, err := conn.Exec(fmt.Sprintf(queryAccountTotal, query)); err != nil {
-		log.Println(query)
+func migrateDown(ctx *cli.Context, conn *pgxpool.Pool) error {
+	if err := migrations.Down(ctx.Context, conn, ctx.Bool(flagAll)); err != nil {
 		return err
 	}
 
-	return nil
+	return dropStaleCheckpoints(ctx.Context, conn)
 }
 
-func writeLikesAndInterests(conn *sqlx.DB, accs []Account) error {
-	likes := make([]domain.LikeModel, 0)
-	interests := make([]domain.InterestModel, 0)
-
-	for _, acc := range accs {
-		likes = append(likes, newLikes(&acc)...)
-		interests = append(interests, newInterests(&acc)...)
-	}
+func applyConstraints(ctx *cli.Context, conn *pgxpool.Pool) error {
+	return migrations.ApplyDeferred(ctx.Context, conn)
+}
 
-	queryLikeTotal := `INSERT INTO likes(liker_id, likee_id, ts) VALUES %s;`
-	queriesLike := make([]string, 0, len(likes))
-	for _, like := range likes {
-		queriesLike = append(queriesLike, fmt.Sprintf(`(%d, %d, %s)`, like.LikerID, like.LikeeID, nullableTimestamp(&like.Timestamp)))
+// load applies the constraints only after the files are loaded, as they slow the load down
+// and the likes refer to the accounts of the later files.
+func load(ctx *cli.Context, conn *pgxpool.Pool) error {
+	paths := ctx.Args().Slice()
+	if len(paths) == 0 {
+		return errEmptyArg
 	}
 
-	if _, err := conn.Exec(fmt.Sprintf(queryLikeTotal, strings.Join(queriesLike, ", "))); err != nil {
-		return err
+	batchSize := ctx.Int(flagBatch)
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
 	}
 
-	queryInterestTotal := `INSERT INTO interest(account_id, name) VALUES %s;`
-	queriesInterest := make([]string, 0, len(interests))
-	for _, i := range interests {
-		queriesInterest = append(queriesInterest, fmt.Sprintf(`(%d, '%s')`, i.AccountID, i.Name))
+	workers := ctx.Int(flagWorkers)
+	if workers <= 0 {
+		workers = runtime.NumCPU()
 	}
 
-	if _, err := conn.Exec(fmt.Sprintf(queryInterestTotal, strings.Join(queriesInterest, ", "))); err != nil {
+	if err := migrations.Up(ctx.Context, conn); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func newAccount(a *Account, countryID, cityID *uuid.UUID) domain.AccountModel {
-	account := domain.AccountModel{
-		ID:        a.ID,
-		Status:    a.Status,
-		Email:     a.Email,
-		Sex:       a.Sex,
-		Birth:     int64PtrToTimestamp(&a.Birth),
-		Name:      a.Name,
-		Surname:   a.Surname,
-		Phone:     a.Phone,
-		CountryID: countryID,
-		CityID:    cityID,
-		Joined:    int64PtrToTimestamp(&a.Joined),
-	}
-
-	if a.Premium != nil {
-		start := int64PtrToTimestamp(&a.Premium.Start)
-		account.PremiumStart = &start
-		end := int64PtrToTimestamp(&a.Premium.End)
-		account.PremiumEnd = &end
+	w, err := newWriter(ctx.Context, conn)
+	if err != nil {
+		return err
 	}
 
-	return account
-}
-
-func newLikes(acc *Account) []domain.LikeModel {
-	likes := make([]domain.LikeModel, 0, len(acc.Likes))
+	defer w.Close()
 
-	for _, like := range acc.Likes {
-		likes = append(likes, domain.LikeModel{
-			LikerID:   acc.ID,
-			LikeeID:   like.UserID,
-			Timestamp: int64PtrToTimestamp(&like.Timestamp),
-		})
+	if err = loadFiles(ctx.Context, conn, w, paths, workers, batchSize); err != nil {
+		return err
 	}
 
-	return likes
-}
-
-func newInterests(acc *Account) []domain.InterestModel {
-	interests := make([]domain.InterestModel, 0, len(acc.Interests))
+	log.Printf("done: %d accounts written in %s", w.Written(), time.Since(w.start))
 
-	for _, interest := range acc.Interests {
-		interests = append(interests, domain.InterestModel{
-			AccountID: acc.ID,
-			Name:      interest,
-		})
-	}
-
-	return interests
+	return migrations.ApplyDeferred(ctx.Context, conn)
 }
 
-func ptrInt32(val int32) *int32 {
-	return &val
-}
+// readBatches streams the accounts of the file, passing them to write in batches of batchSize,
+// so that only one batch is kept in memory whatever the size of the file.
+func readBatches(path string, batchSize int, write func([]Account) error) error {
+	batch := make([]Account, 0, batchSize)
+	err := readFile(path, func(acc Account) error {
+		batch = append(batch, acc)
+		if len(batch) < batchSize {
+			return nil
+		}
 
-func ptrUUID(val uuid.UUID) *uuid.UUID {
-	return &val
-}
+		if err := write(batch); err != nil {
+			return err
+		}
 
-func int64PtrToTimestamp(val *int64) time.Time {
-	if val == nil {
-		return time.Time{}
+		batch = batch[:0]
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return time.Unix(*val, 0)
-}
-
-func nullableTimestamp(ts *time.Time) string {
-	if ts == nil || *ts == (time.Time{}) {
-		return "null"
+	if len(batch) == 0 {
+		return nil
 	}
 
-	return fmt.Sprintf(`'%s'::timestamp`, ts.Format(timestampLayout))
+	return write(batch)
 }
 
-func nullableString(str *string) string {
-	if str == nil {
-		return "null"
+func readFile(path string, next func(Account) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
 	}
 
-	return fmt.Sprintf(`'%s'`, *str)
-}
+	defer file.Close()
 
-func nullableUUID(val *uuid.UUID) string {
-	if val == nil {
-		return "null"
-	}
+	return util.DecodeArray(bufio.NewReader(file), "accounts", func(dec *json.Decoder) error {
+		var acc Account
+		if err := dec.Decode(&acc); err != nil {
+			return err
+		}
 
-	return fmt.Sprintf(`'%s'::uuid`, val.String())
+		return next(acc)
+	})
 }
@@ -0,0 +1,122 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAccountsFile(t *testing.T, n int) string {
+	accounts := make([]string, 0, n)
+	for id := 1; id <= n; id++ {
+		accounts = append(accounts, fmt.Sprintf(`{"id": %d, "email": "%d@mail.ru", "sex": "m", "status": "свободны",
+			"interests": ["бокс"], "likes": [{"id": 1, "ts": 1500000000}]}`, id, id))
+	}
+
+	path := filepath.Join(t.TempDir(), "accounts_1.json")
+	body := `{"version": 1, "accounts": [` + strings.Join(accounts, ",") + `]}`
+	require.NoError(t, ioutil.WriteFile(path, []byte(body), 0644))
+
+	return path
+}
+
+func Test_readBatches(t *testing.T) {
+	path := writeAccountsFile(t, 7)
+
+	var sizes []int
+	var ids []int32
+	err := readBatches(path, 3, func(batch []Account) error {
+		sizes = append(sizes, len(batch))
+		for _, acc := range batch {
+			ids = append(ids, acc.ID)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 3, 1}, sizes)
+	assert.Equal(t, []int32{1, 2, 3, 4, 5, 6, 7}, ids)
+}
+
+func Test_readBatches_WriteError(t *testing.T) {
+	path := writeAccountsFile(t, 7)
+
+	calls := 0
+	err := readBatches(path, 3, func(batch []Account) error {
+		calls++
+		return fmt.Errorf("copy failed")
+	})
+	assert.EqualError(t, err, "copy failed")
+	assert.Equal(t, 1, calls)
+}
+
+// testCopier records the number of the rows of every COPY.
+type testCopier struct {
+	tables []string
+	sizes  []int
+	rows   [][]interface{}
+}
+
+func (c *testCopier) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	size := 0
+	for src.Next() {
+		values, err := src.Values()
+		if err != nil {
+			return 0, err
+		}
+
+		c.rows = append(c.rows, values)
+		size++
+	}
+
+	c.tables = append(c.tables, table.Sanitize())
+	c.sizes = append(c.sizes, size)
+
+	return int64(size), nil
+}
+
+func Test_copyRows_Chunks(t *testing.T) {
+	c := &testCopier{}
+	n := 2*copyChunkSize + 1
+	err := copyRows(context.Background(), c, "likes", likeColumns, n, func(i int) []interface{} {
+		return []interface{}{i}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{copyChunkSize, copyChunkSize, 1}, c.sizes)
+	assert.Equal(t, []string{`"likes"`, `"likes"`, `"likes"`}, c.tables)
+
+	require.Len(t, c.rows, n)
+	for i, row := range c.rows {
+		require.Equal(t, []interface{}{i}, row)
+	}
+}
+
+func Test_copyRows_Empty(t *testing.T) {
+	c := &testCopier{}
+	require.NoError(t, copyRows(context.Background(), c, "likes", likeColumns, 0, nil))
+	assert.Empty(t, c.sizes)
+}
+
+func Test_checkpointName(t *testing.T) {
+	assert.Equal(t, "accounts_1.json", checkpointName("/tmp/data/accounts_1.json"))
+	assert.Equal(t, "accounts_1.json", checkpointName("accounts_1.json"))
+	assert.Equal(t, checkpointName("/tmp/a/accounts_2.json"), checkpointName("/data/accounts_2.json"))
+}
+
+func Test_pendingFiles_SkipsCompleted(t *testing.T) {
+	paths := []string{"/tmp/data/accounts_1.json", "/tmp/data/accounts_2.json", "/tmp/data/accounts_3.json"}
+	completed := map[string]bool{"accounts_2.json": true}
+
+	assert.Equal(t, []string{"/tmp/data/accounts_1.json", "/tmp/data/accounts_3.json"}, pendingFiles(paths, completed))
+	assert.Equal(t, paths, pendingFiles(paths, nil))
+	assert.Empty(t, pendingFiles(paths, map[string]bool{
+		"accounts_1.json": true, "accounts_2.json": true, "accounts_3.json": true,
+	}))
+}
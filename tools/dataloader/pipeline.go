@@ -0,0 +1,173 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// checkpointTable records the files loaded completely, the record is written
+// in the same transaction as the accounts of the file, so a file is either loaded or not.
+const checkpointTable = "loader_checkpoint"
+
+var (
+	createCheckpointsQuery = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    file        varchar(255) primary key,
+    accounts    int not null,
+    loaded_at   timestamp not null default now()
+)`, checkpointTable)
+	selectCheckpointsQuery = fmt.Sprintf(`SELECT file FROM %s`, checkpointTable)
+	insertCheckpointQuery  = fmt.Sprintf(`INSERT INTO %s (file, accounts) VALUES ($1, $2)`, checkpointTable)
+	dropCheckpointsQuery   = fmt.Sprintf(`DROP TABLE IF EXISTS %s`, checkpointTable)
+	schemaExistsQuery      = `SELECT to_regclass('account') IS NOT NULL`
+)
+
+// loadFiles loads the files by the workers concurrently skipping the ones loaded by the previous runs,
+// the first error cancels the rest of the files.
+func loadFiles(ctx context.Context, conn *pgxpool.Pool, w *writer, paths []string, workers, batchSize int) error {
+	completed, err := completedFiles(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	files := make(chan string)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range files {
+				if err := loadFile(ctx, conn, w, path, batchSize); err != nil {
+					errs <- fmt.Errorf("%s: %w", path, err)
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, path := range pendingFiles(paths, completed) {
+		select {
+		case files <- path:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(files)
+	wg.Wait()
+	close(errs)
+
+	if err = <-errs; err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// loadFile writes the accounts of the file and its checkpoint in one transaction.
+func loadFile(ctx context.Context, conn *pgxpool.Pool, w *writer, path string, batchSize int) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	accounts := 0
+	err = readBatches(path, batchSize, func(batch []Account) error {
+		accounts += len(batch)
+		return w.write(ctx, tx, batch)
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, insertCheckpointQuery, checkpointName(path), accounts); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("%s loaded: %d accounts", path, accounts)
+
+	return nil
+}
+
+func completedFiles(ctx context.Context, conn *pgxpool.Pool) (map[string]bool, error) {
+	if _, err := conn.Exec(ctx, createCheckpointsQuery); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, selectCheckpointsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	completed := make(map[string]bool)
+	for rows.Next() {
+		var file string
+		if err = rows.Scan(&file); err != nil {
+			return nil, err
+		}
+
+		completed[file] = true
+	}
+
+	return completed, rows.Err()
+}
+
+// pendingFiles returns the paths of the files not loaded by the previous runs.
+func pendingFiles(paths []string, completed map[string]bool) []string {
+	pending := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if completed[checkpointName(path)] {
+			log.Printf("%s is already loaded, skipped", path)
+			continue
+		}
+
+		pending = append(pending, path)
+	}
+
+	return pending
+}
+
+// checkpointName identifies the file by its name, so the load may be resumed from another directory.
+func checkpointName(path string) string {
+	return filepath.Base(path)
+}
+
+// copier is either the connection pool or a transaction.
+type copier interface {
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// dropStaleCheckpoints drops the checkpoints once the schema of the accounts is reverted,
+// as the next load would skip the files they record.
+func dropStaleCheckpoints(ctx context.Context, conn *pgxpool.Pool) error {
+	var exists bool
+	if err := conn.QueryRow(ctx, schemaExistsQuery).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	_, err := conn.Exec(ctx, dropCheckpointsQuery)
+	return err
+}
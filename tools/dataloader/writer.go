@@ -0,0 +1,304 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"accounts/domain"
+)
+
+// copyChunkSize is the maximum number of rows sent by one COPY.
+const copyChunkSize = 50000
+
+var (
+	accountColumns  = []string{"id", "email", "sex", "status", "birth", "name", "surname", "phone", "country_id", "city_id", "joined", "prem_start", "prem_end"}
+	nameColumns     = []string{"id", "name"}
+	likeColumns     = []string{"liker_id", "likee_id", "ts"}
+	interestColumns = []string{"account_id", "name"}
+)
+
+// writer writes the batches of the accounts, it's shared by the workers. The ids of the cities
+// and the countries are kept for the later batches, they are read from the database on start
+// so that a resumed load refers to the ones written before. It logs the progress after each batch.
+//
+// The cities and the countries are written on a connection of their own acquired on start:
+// the workers hold the connections of the pool for their files, acquiring one of the pool
+// under mu would wait forever once every connection is held by a worker waiting for mu.
+type writer struct {
+	conn *pgxpool.Conn
+
+	mu        sync.Mutex
+	countries map[string]uuid.UUID
+	cities    map[string]uuid.UUID
+
+	start   time.Time
+	written int64
+}
+
+// newWriter acquires the connection of the cities and the countries, it's released by Close.
+func newWriter(ctx context.Context, pool *pgxpool.Pool) (*writer, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &writer{
+		conn:  conn,
+		start: time.Now(),
+	}
+
+	if w.cities, err = readNames(ctx, conn, "city"); err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	if w.countries, err = readNames(ctx, conn, "country"); err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Close releases the connection of the cities and the countries.
+func (w *writer) Close() {
+	w.conn.Release()
+}
+
+// write copies the batch within the transaction of the file.
+func (w *writer) write(ctx context.Context, tx pgx.Tx, accounts []Account) error {
+	countries, cities, err := w.writeCountriesAndCities(ctx, accounts)
+	if err != nil {
+		return err
+	}
+
+	if err = writeAccounts(ctx, tx, accounts, countries, cities); err != nil {
+		return err
+	}
+
+	if err = writeLikesAndInterests(ctx, tx, accounts); err != nil {
+		return err
+	}
+
+	written := atomic.AddInt64(&w.written, int64(len(accounts)))
+	elapsed := time.Since(w.start)
+	log.Printf("%d accounts written, %.0f accounts/s", written, float64(written)/elapsed.Seconds())
+
+	return nil
+}
+
+func (w *writer) Written() int64 {
+	return atomic.LoadInt64(&w.written)
+}
+
+// writeCountriesAndCities writes the countries and the cities not written yet and returns the ids
+// of the ones of the batch. They are committed at once rather than with the file, as the other
+// files refer to them too.
+func (w *writer) writeCountriesAndCities(ctx context.Context, accs []Account) (countries, cities map[string]uuid.UUID, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	newCountries := make([][]interface{}, 0)
+	newCities := make([][]interface{}, 0)
+
+	for _, acc := range accs {
+		if acc.Country != nil {
+			if _, ok := w.countries[*acc.Country]; !ok {
+				w.countries[*acc.Country] = uuid.New()
+				newCountries = append(newCountries, []interface{}{w.countries[*acc.Country], *acc.Country})
+			}
+		}
+
+		if acc.City != nil {
+			if _, ok := w.cities[*acc.City]; !ok {
+				w.cities[*acc.City] = uuid.New()
+				newCities = append(newCities, []interface{}{w.cities[*acc.City], *acc.City})
+			}
+		}
+	}
+
+	if err = copyRows(ctx, w.conn, "city", nameColumns, len(newCities), func(i int) []interface{} {
+		return newCities[i]
+	}); err != nil {
+		return
+	}
+
+	if err = copyRows(ctx, w.conn, "country", nameColumns, len(newCountries), func(i int) []interface{} {
+		return newCountries[i]
+	}); err != nil {
+		return
+	}
+
+	countries = make(map[string]uuid.UUID)
+	cities = make(map[string]uuid.UUID)
+	for _, acc := range accs {
+		if acc.Country != nil {
+			countries[*acc.Country] = w.countries[*acc.Country]
+		}
+
+		if acc.City != nil {
+			cities[*acc.City] = w.cities[*acc.City]
+		}
+	}
+
+	return
+}
+
+func writeAccounts(ctx context.Context, tx pgx.Tx, accs []Account, countries, cities map[string]uuid.UUID) error {
+	accounts := make([]domain.AccountModel, 0, len(accs))
+
+	for i := range accs {
+		acc := &accs[i]
+
+		var countryID, cityID *uuid.UUID
+		if acc.Country != nil {
+			countryID = ptrUUID(countries[*acc.Country])
+		}
+
+		if acc.City != nil {
+			cityID = ptrUUID(cities[*acc.City])
+		}
+
+		accounts = append(accounts, newAccount(acc, countryID, cityID))
+	}
+
+	return copyRows(ctx, tx, "account", accountColumns, len(accounts), func(i int) []interface{} {
+		a := accounts[i]
+		return []interface{}{a.ID, a.Email, a.Sex, a.Status, a.Birth, a.Name, a.Surname,
+			a.Phone, a.CountryID, a.CityID, a.Joined, a.PremiumStart, a.PremiumEnd}
+	})
+}
+
+func writeLikesAndInterests(ctx context.Context, tx pgx.Tx, accs []Account) error {
+	likes := make([]domain.LikeModel, 0)
+	interests := make([]domain.InterestModel, 0)
+
+	for i := range accs {
+		likes = append(likes, newLikes(&accs[i])...)
+		interests = append(interests, newInterests(&accs[i])...)
+	}
+
+	if err := copyRows(ctx, tx, "likes", likeColumns, len(likes), func(i int) []interface{} {
+		return []interface{}{likes[i].LikerID, likes[i].LikeeID, likes[i].Timestamp}
+	}); err != nil {
+		return err
+	}
+
+	return copyRows(ctx, tx, "interest", interestColumns, len(interests), func(i int) []interface{} {
+		return []interface{}{interests[i].AccountID, interests[i].Name}
+	})
+}
+
+// readNames reads the ids of the cities or the countries by name.
+func readNames(ctx context.Context, conn *pgxpool.Conn, table string) (map[string]uuid.UUID, error) {
+	rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT id, name FROM %s`, pgx.Identifier{table}.Sanitize()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+
+		ids[name] = id
+	}
+
+	return ids, rows.Err()
+}
+
+// copyRows copies n rows into the table in chunks of copyChunkSize rows.
+func copyRows(ctx context.Context, conn copier, table string, columns []string, n int, row func(i int) []interface{}) error {
+	for offset := 0; offset < n; offset += copyChunkSize {
+		size := n - offset
+		if size > copyChunkSize {
+			size = copyChunkSize
+		}
+
+		_, err := conn.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromSlice(size, func(i int) ([]interface{}, error) {
+			return row(offset + i), nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newAccount(a *Account, countryID, cityID *uuid.UUID) domain.AccountModel {
+	account := domain.AccountModel{
+		ID:        a.ID,
+		Status:    a.Status,
+		Email:     a.Email,
+		Sex:       a.Sex,
+		Birth:     int64PtrToTimestamp(&a.Birth),
+		Name:      a.Name,
+		Surname:   a.Surname,
+		Phone:     a.Phone,
+		CountryID: countryID,
+		CityID:    cityID,
+		Joined:    int64PtrToTimestamp(&a.Joined),
+	}
+
+	if a.Premium != nil {
+		start := int64PtrToTimestamp(&a.Premium.Start)
+		account.PremiumStart = &start
+		end := int64PtrToTimestamp(&a.Premium.End)
+		account.PremiumEnd = &end
+	}
+
+	return account
+}
+
+func newLikes(acc *Account) []domain.LikeModel {
+	likes := make([]domain.LikeModel, 0, len(acc.Likes))
+
+	for _, like := range acc.Likes {
+		likes = append(likes, domain.LikeModel{
+			LikerID:   acc.ID,
+			LikeeID:   like.UserID,
+			Timestamp: int64PtrToTimestamp(&like.Timestamp),
+		})
+	}
+
+	return likes
+}
+
+func newInterests(acc *Account) []domain.InterestModel {
+	interests := make([]domain.InterestModel, 0, len(acc.Interests))
+
+	for _, interest := range acc.Interests {
+		interests = append(interests, domain.InterestModel{
+			AccountID: acc.ID,
+			Name:      interest,
+		})
+	}
+
+	return interests
+}
+
+func ptrUUID(val uuid.UUID) *uuid.UUID {
+	return &val
+}
+
+func int64PtrToTimestamp(val *int64) time.Time {
+	if val == nil {
+		return time.Time{}
+	}
+
+	return time.Unix(*val, 0).UTC()
+}
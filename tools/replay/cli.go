@@ -0,0 +1,161 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagAddr        = "addr"
+	flagAnswers     = "answers"
+	flagAmmo        = "ammo"
+	flagConcurrency = "concurrency"
+	flagTimeout     = "timeout"
+	flagExamples    = "examples"
+)
+
+var errEmptyAnswers = errors.New("empty answers path")
+
+func Run() error {
+	app := cli.NewApp()
+	app.Usage = "replays the phase requests against a running server and checks the answers"
+	app.Flags = []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagAddr,
+			Usage: "address of the server",
+			Value: "http://127.0.0.1:8888",
+		},
+		&cli.StringFlag{
+			Name:     flagAnswers,
+			Usage:    "answers file of the phase, e.g. answers/phase_1_get.answ",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  flagAmmo,
+			Usage: "ammo file of the phase with the bodies of the POST requests, e.g. ammo/phase_2_post.ammo",
+		},
+		&cli.IntFlag{
+			Name:  flagConcurrency,
+			Usage: "number of requests in flight, keep 1 for the POST phase to apply the updates in order",
+			Value: 1,
+		},
+		&cli.DurationFlag{
+			Name:  flagTimeout,
+			Usage: "timeout of a request",
+			Value: 2 * time.Second,
+		},
+		&cli.IntFlag{
+			Name:  flagExamples,
+			Usage: "number of the mismatches printed",
+			Value: 20,
+		},
+	}
+
+	app.Action = run
+
+	return app.Run(os.Args)
+}
+
+func run(ctx *cli.Context) error {
+	answersPath := ctx.String(flagAnswers)
+	if answersPath == "" {
+		return errEmptyAnswers
+	}
+
+	requests, err := readPhase(answersPath, ctx.String(flagAmmo))
+	if err != nil {
+		return err
+	}
+
+	concurrency := ctx.Int(flagConcurrency)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	client := &http.Client{Timeout: ctx.Duration(flagTimeout)}
+	addr := strings.TrimSuffix(ctx.String(flagAddr), "/")
+	results := replay(client, addr, requests, concurrency)
+
+	if failed := report(os.Stdout, results, ctx.Int(flagExamples)); failed > 0 {
+		return fmt.Errorf("%d of %d requests mismatched", failed, len(results))
+	}
+
+	return nil
+}
+
+// replay sends the requests by the workers, the results are in the order of the requests.
+func replay(client *http.Client, addr string, requests []request, concurrency int) []result {
+	results := make([]result, len(requests))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = check(client, addr, requests[i])
+			}
+		}()
+	}
+
+	for i := range requests {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func check(client *http.Client, addr string, req request) result {
+	res := result{req: req}
+
+	// the ammo has an empty body for the GET requests, they are sent without one as the contest does
+	var body io.Reader
+	if req.method != http.MethodGet {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequest(req.method, addr+req.uri, body)
+	if err != nil {
+		res.mismatch = err.Error()
+		return res
+	}
+
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		res.mismatch = err.Error()
+		return res
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		res.mismatch = err.Error()
+		return res
+	}
+
+	if resp.StatusCode != req.status {
+		res.mismatch = fmt.Sprintf("status: expected %d, got %d", req.status, resp.StatusCode)
+		return res
+	}
+
+	res.mismatch = compareBodies(req.expected, respBody, ordered(req.method))
+	return res
+}
@@ -0,0 +1,211 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	endpointFilter    = "filter"
+	endpointGroup     = "group"
+	endpointRecommend = "recommend"
+	endpointSuggest   = "suggest"
+	endpointNew       = "new"
+	endpointUpdate    = "update"
+	endpointLikes     = "likes"
+)
+
+// ignoredParams don't change the kind of the query.
+var ignoredParams = map[string]bool{
+	"query_id": true,
+	"limit":    true,
+}
+
+// endpoint returns the endpoint of the uri, e.g. filter of /accounts/filter/?sex_eq=m.
+func endpoint(uri string) string {
+	path := uri
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && (parts[1] == endpointFilter || parts[1] == endpointGroup ||
+		parts[1] == endpointNew || parts[1] == endpointLikes):
+		return parts[1]
+	case len(parts) == 3 && (parts[2] == endpointRecommend || parts[2] == endpointSuggest):
+		return parts[2]
+	case len(parts) == 2:
+		return endpointUpdate
+	}
+
+	return path
+}
+
+// combination identifies the kind of the query by the endpoint and the sorted parameters,
+// e.g. filter?city_any,sex_eq. The keys of the groups are a part of the kind.
+func combination(uri string) string {
+	name := endpoint(uri)
+	u, err := url.Parse(uri)
+	if err != nil {
+		return name
+	}
+
+	params := make([]string, 0)
+	for param, values := range u.Query() {
+		switch {
+		case ignoredParams[param]:
+		case param == "keys" && len(values) > 0:
+			params = append(params, "keys="+values[0])
+		default:
+			params = append(params, param)
+		}
+	}
+
+	if len(params) == 0 {
+		return name
+	}
+
+	sort.Strings(params)
+	return name + "?" + strings.Join(params, ",")
+}
+
+// orderedLists are the paths of the arrays sorted by the spec, the accounts and the groups of the answer.
+// The nested arrays, e.g. the interests of an account, are in no particular order.
+var orderedLists = map[string]bool{
+	".accounts": true,
+	".groups":   true,
+}
+
+// ordered tells whether the order of the accounts and the groups of the answer matters:
+// the GET endpoints sort them by the spec, the answers to the updates are empty objects.
+func ordered(method string) bool {
+	return method == "GET"
+}
+
+// compareBodies returns the first difference of the bodies or "" if they are equal.
+// An empty expected body is not checked, only the status is.
+func compareBodies(expected, actual []byte, ordered bool) string {
+	if len(bytes.TrimSpace(expected)) == 0 {
+		return ""
+	}
+
+	want, err := decode(expected)
+	if err != nil {
+		return fmt.Sprintf("expected body isn't json: %v", err)
+	}
+
+	got, err := decode(actual)
+	if err != nil {
+		return fmt.Sprintf("body isn't json: %v", err)
+	}
+
+	return diff("", want, got, ordered)
+}
+
+func decode(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var value interface{}
+	err := dec.Decode(&value)
+	return value, err
+}
+
+func diff(path string, want, got interface{}, ordered bool) string {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return mismatch(path, want, got)
+		}
+
+		keys := make([]string, 0, len(w)+len(g))
+		for key := range w {
+			keys = append(keys, key)
+		}
+		for key := range g {
+			if _, ok := w[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			wv, wok := w[key]
+			gv, gok := g[key]
+			switch {
+			case !gok:
+				return fmt.Sprintf("%s: missing, expected %s", pathOrRoot(path+"."+key), marshal(wv))
+			case !wok:
+				return fmt.Sprintf("%s: unexpected %s", pathOrRoot(path+"."+key), marshal(gv))
+			}
+
+			if d := diff(path+"."+key, wv, gv, ordered); d != "" {
+				return d
+			}
+		}
+
+		return ""
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			return mismatch(path, want, got)
+		}
+
+		if len(w) != len(g) {
+			return fmt.Sprintf("%s: expected %d elements, got %d", pathOrRoot(path), len(w), len(g))
+		}
+
+		if !ordered || !orderedLists[path] {
+			w, g = canonicalOrder(w), canonicalOrder(g)
+		}
+
+		for i := range w {
+			if d := diff(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], ordered); d != "" {
+				return d
+			}
+		}
+
+		return ""
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return mismatch(path, want, got)
+	}
+
+	return ""
+}
+
+// canonicalOrder sorts the elements by their json, so the arrays are compared as multisets.
+func canonicalOrder(values []interface{}) []interface{} {
+	sorted := make([]interface{}, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool {
+		return marshal(sorted[i]) < marshal(sorted[j])
+	})
+
+	return sorted
+}
+
+func mismatch(path string, want, got interface{}) string {
+	return fmt.Sprintf("%s: expected %s, got %s", pathOrRoot(path), marshal(want), marshal(got))
+}
+
+func marshal(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "body"
+	}
+
+	return strings.TrimPrefix(path, ".")
+}
@@ -0,0 +1,157 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	errNoBody      = errors.New("request body isn't known, the ammo file is required")
+	errAmmoMissing = errors.New("ammo and answers differ in length")
+)
+
+// request is a request of a phase with the expected answer.
+type request struct {
+	method string
+	uri    string
+	body   []byte
+
+	status   int
+	expected []byte
+}
+
+// readPhase reads the answers file, each line of which is the method, the uri, the status
+// and the body separated by tabs. The bodies of the POST requests are read from the ammo file.
+func readPhase(answersPath, ammoPath string) ([]request, error) {
+	requests, err := readFile(answersPath, readAnswers)
+	if err != nil {
+		return nil, err
+	}
+
+	if ammoPath == "" {
+		for _, r := range requests {
+			if r.method != http.MethodGet {
+				return nil, fmt.Errorf("%s %s: %w", r.method, r.uri, errNoBody)
+			}
+		}
+
+		return requests, nil
+	}
+
+	ammo, err := readFile(ammoPath, readAmmo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ammo) != len(requests) {
+		return nil, fmt.Errorf("%w: %d requests, %d answers", errAmmoMissing, len(ammo), len(requests))
+	}
+
+	for i := range requests {
+		if ammo[i].method != requests[i].method || ammo[i].uri != requests[i].uri {
+			return nil, fmt.Errorf("request %d: ammo %s %s, answer %s %s",
+				i+1, ammo[i].method, ammo[i].uri, requests[i].method, requests[i].uri)
+		}
+
+		requests[i].body = ammo[i].body
+	}
+
+	return requests, nil
+}
+
+func readFile(path string, read func(r io.Reader) ([]request, error)) ([]request, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	requests, err := read(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return requests, nil
+}
+
+func readAnswers(r io.Reader) ([]request, error) {
+	requests := make([]request, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected method, uri, status and body", line)
+		}
+
+		status, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		req := request{method: fields[0], uri: fields[1], status: status}
+		if len(fields) == 4 {
+			req.expected = []byte(fields[3])
+		}
+
+		requests = append(requests, req)
+	}
+
+	return requests, scanner.Err()
+}
+
+// readAmmo reads the requests in the yandex-tank format: the size of the request and a tag
+// on a separate line followed by the raw http request of the size.
+func readAmmo(r io.Reader) ([]request, error) {
+	br := bufio.NewReader(r)
+	requests := make([]request, 0)
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(header) == "" {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) == 0 {
+			continue
+		}
+
+		size, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(requests)+1, err)
+		}
+
+		raw := make([]byte, size)
+		if _, err = io.ReadFull(br, raw); err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(requests)+1, err)
+		}
+
+		httpReq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(requests)+1, err)
+		}
+
+		body, err := ioutil.ReadAll(httpReq.Body)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", len(requests)+1, err)
+		}
+
+		requests = append(requests, request{method: httpReq.Method, uri: httpReq.RequestURI, body: body})
+	}
+}
@@ -0,0 +1,117 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readAnswers(t *testing.T) {
+	answers := "GET\t/accounts/filter/?sex_eq=m&limit=2&query_id=1\t200\t{\"accounts\": [{\"id\": 2}]}\n" +
+		"GET\t/accounts/1/recommend/?query_id=2\t404\t\n"
+
+	requests, err := readAnswers(strings.NewReader(answers))
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/accounts/filter/?sex_eq=m&limit=2&query_id=1", requests[0].uri)
+	assert.Equal(t, 200, requests[0].status)
+	assert.Equal(t, `{"accounts": [{"id": 2}]}`, string(requests[0].expected))
+	assert.Equal(t, 404, requests[1].status)
+	assert.Empty(t, requests[1].expected)
+}
+
+func Test_readAmmo(t *testing.T) {
+	body := `{"likes": []}`
+	raw := fmt.Sprintf("POST /accounts/likes/?query_id=3 HTTP/1.1\r\nHost: localhost\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+	ammo := fmt.Sprintf("%d post_likes\n%s\n", len(raw), raw)
+
+	requests, err := readAmmo(strings.NewReader(ammo))
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].method)
+	assert.Equal(t, "/accounts/likes/?query_id=3", requests[0].uri)
+	assert.Equal(t, body, string(requests[0].body))
+}
+
+func Test_combination(t *testing.T) {
+	testcases := map[string]string{
+		"/accounts/filter/?sex_eq=m&city_any=a,b&limit=5&query_id=1": "filter?city_any,sex_eq",
+		"/accounts/group/?keys=city,sex&order=-1&limit=5":            "group?keys=city,sex,order",
+		"/accounts/12/recommend/?query_id=1":                         "recommend",
+		"/accounts/12/?query_id=1":                                   "update",
+		"/accounts/new/?query_id=1":                                  "new",
+	}
+
+	for uri, expected := range testcases {
+		assert.Equal(t, expected, combination(uri), uri)
+	}
+}
+
+func Test_compareBodies(t *testing.T) {
+	expected := []byte(`{"accounts": [{"id": 2, "sex": "m"}, {"id": 1, "sex": "f"}]}`)
+
+	assert.Empty(t, compareBodies(expected, []byte(`{"accounts":[{"sex":"m","id":2},{"id":1,"sex":"f"}]}`), true))
+	assert.Equal(t, "accounts[0].id: expected 2, got 1",
+		compareBodies(expected, []byte(`{"accounts": [{"id": 1, "sex": "f"}, {"id": 2, "sex": "m"}]}`), true))
+	assert.Empty(t, compareBodies(expected, []byte(`{"accounts": [{"id": 1, "sex": "f"}, {"id": 2, "sex": "m"}]}`), false))
+	assert.Equal(t, "accounts[1].sex: missing, expected \"f\"",
+		compareBodies(expected, []byte(`{"accounts": [{"id": 2, "sex": "m"}, {"id": 1}]}`), true))
+	assert.Equal(t, "accounts: expected 2 elements, got 1",
+		compareBodies(expected, []byte(`{"accounts": [{"id": 2, "sex": "m"}]}`), true))
+	assert.Empty(t, compareBodies(nil, []byte(`anything`), true))
+
+	// only the top-level lists are ordered
+	expected = []byte(`{"accounts": [{"id": 2, "interests": ["бокс", "кино"]}, {"id": 1}]}`)
+	assert.Empty(t, compareBodies(expected, []byte(`{"accounts": [{"id": 2, "interests": ["кино", "бокс"]}, {"id": 1}]}`), true))
+	assert.Equal(t, "groups[0].count: expected 2, got 1",
+		compareBodies([]byte(`{"groups": [{"count": 2}, {"count": 1}]}`), []byte(`{"groups": [{"count": 1}, {"count": 2}]}`), true))
+}
+
+func Test_replay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && (r.ContentLength != 0 || r.Header.Get("Content-Type") != "") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Path == "/accounts/new/" {
+			body, _ := ioutil.ReadAll(r.Body)
+			if !bytes.Contains(body, []byte(`"id"`)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+			return
+		}
+
+		w.Write([]byte(`{"accounts": [{"id": 1}]}`))
+	}))
+	defer server.Close()
+
+	requests := []request{
+		{method: http.MethodGet, uri: "/accounts/filter/?sex_eq=m", body: []byte{}, status: 200, expected: []byte(`{"accounts": [{"id": 1}]}`)},
+		{method: http.MethodGet, uri: "/accounts/filter/?sex_eq=f", status: 200, expected: []byte(`{"accounts": [{"id": 2}]}`)},
+		{method: http.MethodPost, uri: "/accounts/new/", body: []byte(`{"id": 1}`), status: 201, expected: []byte("{}")},
+		{method: http.MethodPost, uri: "/accounts/new/", body: []byte(`{}`), status: 201},
+	}
+
+	results := replay(server.Client(), server.URL, requests, 2)
+	require.Len(t, results, 4)
+	assert.Empty(t, results[0].mismatch)
+	assert.Equal(t, "accounts[0].id: expected 2, got 1", results[1].mismatch)
+	assert.Empty(t, results[2].mismatch)
+	assert.Equal(t, "status: expected 201, got 400", results[3].mismatch)
+
+	var out bytes.Buffer
+	assert.Equal(t, 2, report(&out, results, 1))
+	assert.Contains(t, out.String(), "2 of 4 requests mismatched")
+}
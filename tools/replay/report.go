@@ -0,0 +1,89 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// result is the outcome of a replayed request, mismatch is empty if the answer is the expected one.
+type result struct {
+	req      request
+	mismatch string
+}
+
+// counts are the numbers of the requests and the mismatches of a group of the requests.
+type counts struct {
+	name   string
+	total  int
+	failed int
+}
+
+// report prints the mismatches per endpoint and per combination of the parameters
+// followed by the first examples of the mismatches.
+func report(w io.Writer, results []result, examples int) (failed int) {
+	byEndpoint := make(map[string]*counts)
+	byCombination := make(map[string]*counts)
+	for _, r := range results {
+		failed += count(byEndpoint, endpoint(r.req.uri), r.mismatch)
+		count(byCombination, combination(r.req.uri), r.mismatch)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	writeCounts(tw, "endpoint", byEndpoint)
+	fmt.Fprintln(tw)
+	writeCounts(tw, "combination", byCombination)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d of %d requests mismatched\n", failed, len(results))
+	for _, r := range results {
+		if examples == 0 {
+			break
+		}
+
+		if r.mismatch != "" {
+			fmt.Fprintf(w, "%s %s: %s\n", r.req.method, r.req.uri, r.mismatch)
+			examples--
+		}
+	}
+
+	return failed
+}
+
+func count(groups map[string]*counts, name, mismatch string) int {
+	c, ok := groups[name]
+	if !ok {
+		c = &counts{name: name}
+		groups[name] = c
+	}
+
+	c.total++
+	if mismatch == "" {
+		return 0
+	}
+
+	c.failed++
+	return 1
+}
+
+// writeCounts writes the groups with the most mismatches first.
+func writeCounts(w io.Writer, title string, groups map[string]*counts) {
+	sorted := make([]*counts, 0, len(groups))
+	for _, c := range groups {
+		sorted = append(sorted, c)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].failed != sorted[j].failed {
+			return sorted[i].failed > sorted[j].failed
+		}
+
+		return sorted[i].name < sorted[j].name
+	})
+
+	fmt.Fprintf(w, "%s\ttotal\tfailed\t\n", title)
+	for _, c := range sorted {
+		fmt.Fprintf(w, "%s\t%d\t%d\t\n", c.name, c.total, c.failed)
+	}
+}
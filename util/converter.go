@@ -6,12 +6,14 @@ import (
 	"github.com/google/uuid"
 )
 
+// TimestampToDatetime converts the unix timestamp to the time in UTC: the columns are timestamps
+// without time zone, their wall clock is read as UTC when the unix timestamps are selected back.
 func TimestampToDatetime(ts *int64) *time.Time {
 	if ts == nil {
 		return nil
 	}
 
-	res := time.Unix(*ts, 0)
+	res := time.Unix(*ts, 0).UTC()
 	return &res
 }
 
@@ -2,7 +2,6 @@ package util
 
 import (
 	"io/ioutil"
-	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -17,9 +16,20 @@ func ReadURLParam(r *http.Request, name string) string {
 	return chi.URLParam(r, name)
 }
 
-func WriteErrorResponse(w http.ResponseWriter, err error, status int) {
+func WriteErrorResponse(w http.ResponseWriter, status int) {
 	w.WriteHeader(status)
-	log.Println(err)
+}
+
+func WriteErrorResponseWithBody(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func WriteTextResponse(w http.ResponseWriter, body []byte, status int) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(body)
 }
 
 func WriteSuccessResponse(w http.ResponseWriter, body []byte, status int) {
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DecodeArray streams the elements of the array in the field of the top level object,
+// e.g. the accounts of {"accounts": [...]}, calling next to decode each of them.
+// The other fields are skipped, the whole document is never kept in memory.
+func DecodeArray(r io.Reader, field string, next func(dec *json.Decoder) error) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if token != field {
+			var skipped json.RawMessage
+			if err = dec.Decode(&skipped); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err = expectDelim(dec, '['); err != nil {
+			return err
+		}
+
+		for dec.More() {
+			if err = next(dec); err != nil {
+				return err
+			}
+		}
+
+		if err = expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("unexpected token %v, expected %v", token, delim)
+	}
+
+	return nil
+}
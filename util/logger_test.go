@@ -0,0 +1,52 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Logger(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLogger(&out, LevelInfo).With("request_id", "host/1")
+
+	l.Debug("skipped")
+	l.Info("request", "status", 200, "endpoint", "/accounts/filter/")
+	l.Error("failed", "error", errors.New("no such table"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], " level=info msg=request request_id=host/1 status=200 endpoint=/accounts/filter/")
+	assert.Contains(t, lines[1], ` level=error msg=failed request_id=host/1 error="no such table"`)
+
+	assert.False(t, l.Enabled(LevelDebug))
+	assert.True(t, l.Enabled(LevelWarn))
+}
+
+func Test_Logger_Nil(t *testing.T) {
+	var l *Logger
+	l.With("key", "value").Error("discarded")
+	assert.False(t, l.Enabled(LevelError))
+}
+
+func Test_LoggerFrom(t *testing.T) {
+	fallback := NewLogger(&bytes.Buffer{}, LevelInfo)
+	assert.Equal(t, fallback, LoggerFrom(context.Background(), fallback))
+
+	l := fallback.With("request_id", "1")
+	assert.Equal(t, l, LoggerFrom(WithLogger(context.Background(), l), fallback))
+}
+
+func Test_ParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, LevelWarn, level)
+
+	_, err = ParseLevel("trace")
+	assert.Error(t, err)
+}